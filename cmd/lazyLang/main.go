@@ -6,7 +6,9 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/lazydiv/lazyLang-compiler/internal/checker"
 	"github.com/lazydiv/lazyLang-compiler/internal/codegen"
+	"github.com/lazydiv/lazyLang-compiler/internal/diagnostics"
 	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
 	"github.com/lazydiv/lazyLang-compiler/internal/parser"
 )
@@ -20,22 +22,52 @@ func main() {
 	filename := os.Args[1]
 	source, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
-		os.Exit(1)
+		fatalf("cannot read %s: %v", filename, err)
 	}
 
-	l := lexer.NewLexer(string(source))
+	printer := diagnostics.NewPrinter(os.Stderr, string(source))
+
+	l := lexer.NewFileLexer(filename, string(source))
 	p := parser.NewParser(l)
 	program := p.ParseProgram()
 
-	cg := codegen.NewCodeGen()
+	var diags []*diagnostics.Diagnostic
+	for _, err := range l.Errors() {
+		diags = append(diags, diagnostics.New(diagnostics.Error, err.Pos, err.End, err.Msg))
+	}
+	for _, err := range p.Errors() {
+		diags = append(diags, diagnostics.New(diagnostics.Error, err.Pos, err.End, err.Msg))
+	}
+	if diagnostics.HasErrors(diags) {
+		printer.PrintAll(diags)
+		os.Exit(1)
+	}
+
+	c := checker.NewChecker()
+	diags = append(diags, c.Check(program)...)
+	if diagnostics.HasErrors(diags) {
+		printer.PrintAll(diags)
+		os.Exit(1)
+	}
+
+	cg := codegen.NewCodeGen(c.Info())
 	goCode := cg.Generate(program)
+	for _, err := range cg.Errors() {
+		diags = append(diags, diagnostics.New(diagnostics.Error, err.Pos, err.End, err.Msg))
+	}
+	if diagnostics.HasErrors(diags) {
+		printer.PrintAll(diags)
+		os.Exit(1)
+	}
+
+	if len(diags) > 0 {
+		printer.PrintAll(diags)
+	}
 
 	outFile := strings.TrimSuffix(filename, ".lazy") + ".go"
 	err = os.WriteFile(outFile, []byte(goCode), 0644)
 	if err != nil {
-		fmt.Printf("Error writing output file: %v\n", err)
-		os.Exit(1)
+		fatalf("cannot write %s: %v", outFile, err)
 	}
 
 	fmt.Printf("Compiled %s to %s\n", filename, outFile)
@@ -46,8 +78,14 @@ func main() {
 	cmd.Stderr = os.Stderr
 	err = cmd.Run()
 	if err != nil {
-		fmt.Printf("Error running output file: %v\n", err)
-		os.Exit(1)
+		fatalf("running %s failed: %v", outFile, err)
 	}
 
 }
+
+// fatalf reports an error that is not tied to a source position and exits.
+func fatalf(format string, args ...interface{}) {
+	d := &diagnostics.Diagnostic{Severity: diagnostics.Error, Message: fmt.Sprintf(format, args...)}
+	fmt.Fprintln(os.Stderr, d.Error())
+	os.Exit(1)
+}
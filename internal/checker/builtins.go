@@ -0,0 +1,380 @@
+package checker
+
+import (
+	"go/constant"
+	"go/token"
+	"math"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// builtins are the functions every program can call. A variable with the same
+// name hides them, unless the generated Go needs the name (see goNames).
+var builtins = map[string]bool{
+	"int":      true,
+	"float":    true,
+	"len":      true,
+	"append":   true,
+	"push":     true,
+	"pop":      true,
+	"slice":    true,
+	"contains": true,
+}
+
+func isBuiltin(name string) bool {
+	return builtins[name]
+}
+
+// values checks a list of expressions and returns their types.
+func (c *Checker) values(exprs []parser.Expression) []*Type {
+	types := make([]*Type, len(exprs))
+	for i, expr := range exprs {
+		types[i] = c.value(expr)
+	}
+	return types
+}
+
+// arity reports whether the call e of the built-in name has want arguments.
+// If not, the arguments are still checked so that they count as used.
+func (c *Checker) arity(e *parser.CallExpression, name string, want int) bool {
+	if len(e.Arguments) == want {
+		return true
+	}
+	c.values(e.Arguments)
+	c.errorf(e, "wrong number of arguments to %s: want %d, got %d", name, want, len(e.Arguments))
+	return false
+}
+
+// elementOf returns the element type of the array argument of a built-in,
+// or nil after reporting an error if it is not an array.
+func (c *Checker) elementOf(name string, arg parser.Expression, typ *Type) *Type {
+	if typ == nil {
+		return nil
+	}
+	if typ.Kind != Array {
+		c.errorf(arg, "first argument to %s must be an array, not %s", name, typ)
+		return nil
+	}
+	return typ.Elem
+}
+
+// checkBuiltin checks a call of a built-in function. The array built-ins map
+// onto Go slices:
+//
+//	len(a)            len(a), also the length of a string in bytes
+//	append(a, v...)   a copy of a with the values added at the end
+//	push(a, v...)     a = append(a, v...), a must be a variable or element
+//	pop(a)            removes and returns the last element of a
+//	slice(a, lo, hi)  a copy of the elements lo up to hi, or a substring
+//	contains(a, v)    whether a has an element equal to v, or a substring v
+//
+// Values added to an array are converted to its element type, so ints can
+// be pushed onto a float array.
+func (c *Checker) checkBuiltin(name string, e *parser.CallExpression) *Type {
+	c.info.Builtins[e] = name
+
+	switch name {
+	case "int", "float":
+		// int(x) truncates a float towards zero, float(x) widens an int
+		if !c.arity(e, name, 1) {
+			return nil
+		}
+		arg := c.value(e.Arguments[0])
+		if arg != nil && !arg.IsNumeric() {
+			c.errorf(e.Arguments[0], "cannot convert %s (type %s) to %s", e.Arguments[0].String(), arg, name)
+			return nil
+		}
+		// Go converts a constant only if int can hold it exactly
+		if name == "int" && arg != nil && arg.Kind == Float && c.isConstant(e.Arguments[0]) {
+			v := c.constValue(e.Arguments[0])
+			if v == nil {
+				return IntType
+			}
+			if i := constant.ToInt(v); i.Kind() != constant.Int {
+				c.errorf(e.Arguments[0], "cannot convert constant %s to int, it has a fraction", e.Arguments[0].String()).
+					WithNote("Go does not truncate constants, only variables").
+					WithHelp("write the integer value directly")
+			} else if _, exact := constant.Int64Val(i); !exact {
+				c.errorf(e.Arguments[0], "constant %s overflows int", e.Arguments[0].String())
+			}
+			return IntType
+		}
+		if name == "int" {
+			return IntType
+		}
+		return FloatType
+
+	case "len":
+		if !c.arity(e, name, 1) {
+			return IntType
+		}
+		if arg := c.value(e.Arguments[0]); arg != nil && arg.Kind != Array && arg.Kind != String {
+			c.errorf(e.Arguments[0], "cannot take the length of %s (type %s)", e.Arguments[0].String(), arg)
+		}
+		return IntType
+
+	case "append", "push":
+		if len(e.Arguments) < 2 {
+			c.values(e.Arguments)
+			c.errorf(e, "not enough arguments to %s: want an array and at least one value", name)
+			return nil
+		}
+
+		// push stores the longer array back, so it needs somewhere to store it
+		var array *Type
+		if name == "push" {
+			array = c.target(e.Arguments[0], true)
+		} else {
+			array = c.value(e.Arguments[0])
+		}
+		elem := c.elementOf(name, e.Arguments[0], array)
+		for _, v := range e.Arguments[1:] {
+			t := c.valueFor(v, elem)
+			if elem != nil && t != nil && !c.convert(v, t, elem) {
+				c.errorf(v, "cannot %s %s (type %s) to %s", name, v.String(), t, array)
+			} else if name == "push" {
+				c.checkShared(v, "push %s to %s", v.String(), e.Arguments[0].String())
+			}
+		}
+
+		if name == "push" {
+			return VoidType
+		}
+		if elem == nil {
+			return nil
+		}
+		return array
+
+	case "pop":
+		if !c.arity(e, name, 1) {
+			return nil
+		}
+		// Go does not order a call against plain reads of a variable in
+		// the same expression, so nothing else may read a
+		if e != c.pop {
+			arg := e.Arguments[0].String()
+			c.errorf(e, "pop(%s) must be a statement or the value assigned to a variable", arg).
+				WithNote("pop changes %s, and other reads of %s in the same expression may see it before or after", arg, arg).
+				WithHelp("pop it first: lazy last = pop(%s)", arg)
+		}
+		return c.elementOf(name, e.Arguments[0], c.target(e.Arguments[0], true))
+
+	case "slice":
+		if !c.arity(e, name, 3) {
+			return nil
+		}
+		args := c.values(e.Arguments)
+		for i, bound := range e.Arguments[1:] {
+			if t := args[i+1]; t != nil && t.Kind != Int {
+				c.errorf(bound, "slice bound %s is %s, not int", bound.String(), t)
+			}
+		}
+		if args[0] != nil && args[0].Kind != Array && args[0].Kind != String {
+			c.errorf(e.Arguments[0], "cannot slice %s (type %s)", e.Arguments[0].String(), args[0])
+			return nil
+		}
+		return args[0]
+
+	case "contains":
+		if !c.arity(e, name, 2) {
+			return BoolType
+		}
+		haystack, needle := e.Arguments[0], e.Arguments[1]
+		typ := c.value(haystack)
+		if typ != nil && typ.Kind == String {
+			if t := c.value(needle); t != nil && t.Kind != String {
+				c.errorf(needle, "cannot search string %s for %s (type %s)", haystack.String(), needle.String(), t)
+			}
+			return BoolType
+		}
+
+		elem := c.elementOf(name, haystack, typ)
+		t := c.valueFor(needle, elem)
+		switch {
+		case elem == nil || t == nil:
+		case elem.Kind == Array:
+			c.errorf(e, "cannot search %s, arrays of arrays cannot be compared", haystack.String())
+		case !elem.Comparable():
+			c.errorf(e, "cannot search %s, %s has an array field and cannot be compared", haystack.String(), elem)
+		case !c.convert(needle, t, elem):
+			c.errorf(needle, "cannot search %s (type %s) for %s (type %s)", haystack.String(), typ, needle.String(), t)
+		}
+		return BoolType
+	}
+	return nil
+}
+
+// isConstant reports whether expr is built from literals and constants only.
+// Go evaluates such expressions at compile time, and refuses to truncate them
+// to int. ** is the exception, it needs a function call.
+func (c *Checker) isConstant(expr parser.Expression) bool {
+	switch e := expr.(type) {
+	case *parser.IntegerLiteral, *parser.FloatLiteral, *parser.StringLiteral, *parser.BooleanLiteral:
+		return true
+	case *parser.Identifier:
+		sym := c.scope.lookup(e.Value)
+		return sym != nil && sym.constant
+	case *parser.PrefixExpression:
+		return c.isConstant(e.Right)
+	case *parser.InfixExpression:
+		return e.Operator != "**" && c.isConstant(e.Left) && c.isConstant(e.Right)
+	default:
+		return false
+	}
+}
+
+// constOps maps the operators that constValue folds to their go/token
+// spelling.
+var constOps = map[string]token.Token{
+	"+": token.ADD, "-": token.SUB, "*": token.MUL, "/": token.QUO, "%": token.REM,
+	"&": token.AND, "|": token.OR, "^": token.XOR, "<<": token.SHL, ">>": token.SHR,
+}
+
+// maxShift is the largest constant shift count that constValue folds.
+const maxShift = 1023
+
+// constValue returns the value of a constant expression, or nil if expr is
+// not one or its value is unknown. Like Go, integer operands divide without
+// a fraction. Floats are rounded to float64 after every operation, as they
+// are in the generated Go, which gives them that type.
+func (c *Checker) constValue(expr parser.Expression) constant.Value {
+	switch e := expr.(type) {
+	case *parser.IntegerLiteral:
+		return constant.MakeInt64(e.Value)
+	case *parser.FloatLiteral:
+		return constant.MakeFloat64(e.Value)
+	case *parser.StringLiteral:
+		return constant.MakeString(e.Value)
+	case *parser.BooleanLiteral:
+		return constant.MakeBool(e.Value)
+	case *parser.Identifier:
+		if sym := c.scope.lookup(e.Value); sym != nil && sym.constant {
+			return sym.value
+		}
+	case *parser.PrefixExpression:
+		x := c.constValue(e.Right)
+		switch {
+		case x == nil:
+		case e.Operator == "-" && isNumeric(x):
+			return constant.UnaryOp(token.SUB, x, 0)
+		case e.Operator == "!" && x.Kind() == constant.Bool:
+			return constant.UnaryOp(token.NOT, x, 0)
+		}
+	case *parser.InfixExpression:
+		op, ok := constOps[e.Operator]
+		x, y := c.constValue(e.Left), c.constValue(e.Right)
+		if !ok || x == nil || y == nil {
+			return nil
+		}
+		numeric := isNumeric(x) && isNumeric(y)
+		ints := x.Kind() == constant.Int && y.Kind() == constant.Int
+		switch op {
+		case token.ADD:
+			if !numeric && (x.Kind() != constant.String || y.Kind() != constant.String) {
+				return nil
+			}
+		case token.SUB, token.MUL:
+			if !numeric {
+				return nil
+			}
+		case token.QUO:
+			if !numeric || constant.Sign(y) == 0 {
+				return nil
+			}
+			if ints {
+				op = token.QUO_ASSIGN // integer division
+			}
+		case token.SHL, token.SHR:
+			n, exact := constant.Uint64Val(y)
+			if !ints || !exact || n > maxShift {
+				return nil
+			}
+			return constant.Shift(x, op, uint(n))
+		default:
+			if !ints || (op == token.REM && constant.Sign(y) == 0) {
+				return nil
+			}
+		}
+		return roundFloat(constant.BinaryOp(x, op, y))
+	}
+	return nil
+}
+
+// roundFloat rounds a float constant to float64, unless it overflows.
+func roundFloat(v constant.Value) constant.Value {
+	if v.Kind() != constant.Float {
+		return v
+	}
+	if f, _ := constant.Float64Val(v); !math.IsInf(f, 0) {
+		return constant.MakeFloat64(f)
+	}
+	return v
+}
+
+func isNumeric(v constant.Value) bool {
+	return v.Kind() == constant.Int || v.Kind() == constant.Float
+}
+
+// checkOverflow reports a constant expression whose value does not fit in
+// its type, which Go rejects. Only the innermost such expression is reported.
+func (c *Checker) checkOverflow(e parser.Expression, operands ...parser.Expression) {
+	if !c.isConstant(e) {
+		return
+	}
+	for _, operand := range operands {
+		if c.overflows(operand) {
+			return
+		}
+	}
+	switch {
+	case !c.overflows(e):
+	case c.constValue(e).Kind() == constant.Float:
+		c.errorf(e, "constant %s overflows float", e.String())
+	default:
+		c.errorf(e, "constant %s overflows int", e.String()).
+			WithNote("an int holds values from %d to %d", math.MinInt64, math.MaxInt64)
+	}
+}
+
+// overflows reports whether expr is a constant whose value does not fit in
+// int or float.
+func (c *Checker) overflows(expr parser.Expression) bool {
+	v := c.constValue(expr)
+	switch {
+	case v == nil:
+		return false
+	case v.Kind() == constant.Int:
+		_, exact := constant.Int64Val(v)
+		return !exact
+	case v.Kind() == constant.Float:
+		f, _ := constant.Float64Val(v)
+		return math.IsInf(f, 0)
+	}
+	return false
+}
+
+// checkShift reports a constant shift count that Go rejects.
+func (c *Checker) checkShift(e *parser.InfixExpression) {
+	if !c.isConstant(e.Right) {
+		return
+	}
+	n := c.constValue(e.Right)
+	switch {
+	case n == nil:
+	case constant.Sign(n) < 0:
+		c.errorf(e.Right, "invalid negative shift count %s", e.Right.String())
+	case c.isConstant(e.Left) && constant.Compare(n, token.GTR, constant.MakeInt64(maxShift)):
+		c.errorf(e.Right, "invalid shift count %s", e.Right.String()).
+			WithNote("a constant can be shifted by at most %d", maxShift)
+	}
+}
+
+// checkDivisor reports a division by a constant zero, which Go rejects.
+func (c *Checker) checkDivisor(divisor parser.Expression) {
+	if !c.isConstant(divisor) {
+		return
+	}
+	if v := c.constValue(divisor); v != nil && isNumeric(v) && constant.Sign(v) == 0 {
+		c.errorf(divisor, "division by zero")
+	}
+}
@@ -0,0 +1,1207 @@
+package checker
+
+import (
+	"github.com/lazydiv/lazyLang-compiler/internal/diagnostics"
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// Checker resolves every identifier in a program to its declaration and
+// gives every expression a static type. It reports names that are used before
+// they are declared, assigned without being declared, or declared and never
+// used, as well as type mismatches. It runs between the parser and the code
+// generator, so these mistakes are reported against the LazyLang source
+// instead of the generated Go.
+type Checker struct {
+	universe *scope // functions, visible everywhere
+	scope    *scope
+	function *symbol // function being checked, nil in main
+	declared map[string][]lexer.Position
+	loops    []*loop                       // loops around the statement being checked
+	labels   map[string]*parser.Identifier // loop labels of the function
+	lazy     *symbol                       // lazyOnce binding whose value is being checked
+	writes   map[string][]bool             // parameters each function modifies
+	pop      parser.Expression             // call that may be a pop, see checkBuiltin
+
+	info  *Info
+	diags []*diagnostics.Diagnostic
+}
+
+// loop is a loop whose body is being checked, for break and continue.
+type loop struct {
+	label     *parser.Identifier // nil for a loop without label
+	labelUsed bool
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		info: &Info{
+			Types:    make(map[parser.Expression]*Type),
+			Decls:    make(map[parser.Statement]*Type),
+			Promoted: make(map[parser.Expression]bool),
+			Consts:   make(map[parser.Statement]bool),
+			Unused:   make(map[parser.Statement]bool),
+			Lazy:     make(map[parser.Expression]bool),
+			Builtins: make(map[*parser.CallExpression]string),
+			Records:  make(map[*parser.CallExpression]*Type),
+		},
+	}
+}
+
+// Diagnostics returns the errors and warnings found by Check.
+func (c *Checker) Diagnostics() []*diagnostics.Diagnostic {
+	return c.diags
+}
+
+// Info returns the types computed by Check.
+func (c *Checker) Info() *Info {
+	return c.info
+}
+
+func (c *Checker) errorf(node parser.Node, format string, args ...interface{}) *diagnostics.Diagnostic {
+	d := diagnostics.Errorf(node.Pos(), node.End(), format, args...)
+	c.diags = append(c.diags, d)
+	return d
+}
+
+func (c *Checker) warnf(node parser.Node, format string, args ...interface{}) *diagnostics.Diagnostic {
+	d := diagnostics.Warningf(node.Pos(), node.End(), format, args...)
+	c.diags = append(c.diags, d)
+	return d
+}
+
+// Check checks program and returns the diagnostics it found.
+func (c *Checker) Check(program *parser.Program) []*diagnostics.Diagnostic {
+	c.universe = newScope(functionScope, nil)
+
+	// Records and functions can be used before they are declared, so
+	// declare them all up front. Records come first, function signatures
+	// may refer to them.
+	var records []*parser.RecordStatement
+	for _, stmt := range program.Statements {
+		if rec, ok := stmt.(*parser.RecordStatement); ok {
+			records = append(records, rec)
+		}
+	}
+	c.declareRecords(records)
+
+	var body []parser.Statement
+	var functions []*parser.FunctionStatement
+	for _, stmt := range program.Statements {
+		if _, ok := stmt.(*parser.RecordStatement); ok {
+			continue
+		}
+		fn, ok := stmt.(*parser.FunctionStatement)
+		if !ok {
+			body = append(body, stmt)
+			continue
+		}
+		// Go runs main and init itself
+		if fn.Name == "main" || fn.Name == "init" || isBuiltin(fn.Name) {
+			c.errorf(fn, "cannot declare a function named %s", fn.Name).
+				WithHelp("choose another name")
+		} else {
+			c.checkName(fn, fn.Name, false)
+		}
+		if prev := c.universe.symbols[fn.Name]; prev != nil {
+			what := "function"
+			if prev.kind == recordSymbol {
+				what = "record"
+			}
+			c.errorf(fn, "%s is already declared as a %s", fn.Name, what).
+				WithNote("%s was first declared at %s", fn.Name, prev.pos)
+			continue
+		}
+		c.universe.declare(&symbol{
+			name: fn.Name,
+			kind: functionSymbol,
+			pos:  fn.Pos(),
+			end:  fn.End(),
+			sig:  c.signature(fn),
+			used: true,
+		})
+		functions = append(functions, fn)
+	}
+
+	c.writes = paramWrites(functions)
+	declared := make(map[*parser.FunctionStatement]bool)
+	for _, fn := range functions {
+		declared[fn] = true
+	}
+
+	// Function bodies are checked where they appear between the statements
+	// of main, so that a record field without a type takes it from the first
+	// construction in the source
+	main := newScope(functionScope, c.universe)
+	mainDeclared := collectDeclarations(body)
+	mainLabels := make(map[string]*parser.Identifier)
+	for _, stmt := range program.Statements {
+		switch s := stmt.(type) {
+		case *parser.RecordStatement:
+		case *parser.FunctionStatement:
+			if declared[s] {
+				c.checkFunction(s)
+			}
+		default:
+			c.scope, c.declared, c.labels = main, mainDeclared, mainLabels
+			c.checkStatement(s)
+		}
+	}
+	c.scope = main
+	c.closeScope()
+
+	c.checkRecords(records)
+	return c.diags
+}
+
+func (c *Checker) signature(fn *parser.FunctionStatement) *Signature {
+	sig := &Signature{Result: VoidType}
+	for _, p := range fn.Parameters {
+		sig.Params = append(sig.Params, c.resolveType(p.Type))
+	}
+	if fn.ReturnType != nil {
+		sig.Result = c.resolveType(fn.ReturnType)
+	}
+	return sig
+}
+
+// resolveType returns the type named by a type annotation, or nil if it does
+// not name a type.
+func (c *Checker) resolveType(te *parser.TypeExpr) *Type {
+	if te.Elem != nil {
+		if elem := c.resolveType(te.Elem); elem != nil {
+			return ArrayOf(elem)
+		}
+		return nil
+	}
+
+	switch te.Name {
+	case "int":
+		return IntType
+	case "float":
+		return FloatType
+	case "bool":
+		return BoolType
+	case "string":
+		return StringType
+	}
+
+	if sym := c.universe.symbols[te.Name]; sym != nil && sym.kind == recordSymbol {
+		return sym.typ
+	}
+	c.errorf(te, "unknown type %s", te.Name)
+	return nil
+}
+
+func (c *Checker) openScope(kind scopeKind) {
+	c.scope = newScope(kind, c.scope)
+}
+
+// closeScope leaves the current scope and reports its unused variables. Go
+// refuses to compile those, so they are errors here as well. Variables whose
+// type is unknown already have an error of their own. Unused constants are
+// fine, like in Go.
+func (c *Checker) closeScope() {
+	for _, sym := range c.scope.ordered {
+		if sym.immutable && !sym.lazy {
+			if !sym.used && sym.decl != nil {
+				c.info.Unused[sym.decl] = true
+			}
+			continue
+		}
+		if sym.kind == variableSymbol && !sym.used && sym.typ != nil {
+			d := diagnostics.Errorf(sym.pos, sym.end, "declared and not used: %s", sym.name)
+			c.diags = append(c.diags, d.WithHelp("remove the declaration or use %s", sym.name))
+		}
+	}
+	c.scope = c.scope.parent
+}
+
+func (c *Checker) checkFunction(fn *parser.FunctionStatement) {
+	c.function = c.universe.symbols[fn.Name]
+	c.declared = collectDeclarations(fn.Body)
+	c.labels = make(map[string]*parser.Identifier)
+	c.scope = newScope(functionScope, c.universe)
+
+	for i, p := range fn.Parameters {
+		c.checkName(p, p.Name, false)
+		if prev := c.scope.symbols[p.Name]; prev != nil {
+			c.errorf(p, "duplicate parameter %s", p.Name)
+			continue
+		}
+		c.scope.declare(&symbol{
+			name: p.Name,
+			kind: parameterSymbol,
+			pos:  p.Pos(),
+			end:  p.End(),
+			typ:  c.function.sig.Params[i],
+		})
+	}
+
+	c.openScope(blockScope)
+	c.checkStatements(fn.Body)
+	c.closeScope()
+
+	if result := c.function.sig.Result; result != nil && result != VoidType && !terminates(fn.Body) {
+		d := diagnostics.Errorf(fn.End(), fn.End(), "missing return at the end of %s", fn.Name)
+		c.diags = append(c.diags, d.WithNote("%s returns %s", fn.Name, result))
+	}
+
+	c.closeScope()
+	c.function = nil
+}
+
+// terminates reports whether a block always ends in a return statement.
+func terminates(stmts []parser.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch s := stmts[len(stmts)-1].(type) {
+	case *parser.ReturnStatement:
+		return true
+	case *parser.IfStatement:
+		return terminates(s.Consequence) && terminates(s.Alternative)
+	case *parser.MatchStatement:
+		// Only a `_` arm guarantees that one of the arms runs
+		hasDefault := false
+		for _, arm := range s.Arms {
+			if len(arm.Patterns) == 0 {
+				hasDefault = true
+			}
+			if !terminates(arm.Body) {
+				return false
+			}
+		}
+		return hasDefault
+	default:
+		return false
+	}
+}
+
+func (c *Checker) checkStatements(stmts []parser.Statement) {
+	for _, stmt := range stmts {
+		c.checkStatement(stmt)
+	}
+}
+
+func (c *Checker) checkBlock(stmts []parser.Statement) {
+	c.openScope(blockScope)
+	c.checkStatements(stmts)
+	c.closeScope()
+}
+
+func (c *Checker) checkStatement(stmt parser.Statement) {
+	switch s := stmt.(type) {
+	case *parser.VarStatement:
+		c.pop = s.Value
+		c.checkVar(s)
+
+	case *parser.ConstStatement:
+		c.checkConst(s)
+
+	case *parser.LazyStatement:
+		c.checkLazy(s)
+
+	case *parser.ArrayStatement:
+		c.checkArray(s)
+
+	case *parser.ForStatement:
+		c.openScope(loopScope)
+		if init, ok := s.Init.(*parser.VarStatement); ok {
+			c.checkVar(init)
+		}
+		if s.Condition != nil {
+			c.checkCondition(s.Condition)
+		}
+		if s.Post != nil {
+			c.checkStatement(s.Post)
+		}
+		c.checkLoop(s.Label, s.Body)
+		c.closeScope()
+
+	case *parser.ForInStatement:
+		c.checkForIn(s)
+
+	case *parser.WhileStatement:
+		c.checkCondition(s.Condition)
+		c.checkLoop(s.Label, s.Body)
+
+	case *parser.BranchStatement:
+		c.checkBranch(s)
+
+	case *parser.IfStatement:
+		c.checkCondition(s.Condition)
+		c.checkBlock(s.Consequence)
+		if s.Alternative != nil {
+			c.checkBlock(s.Alternative)
+		}
+
+	case *parser.MatchStatement:
+		c.checkMatch(s)
+
+	case *parser.FunctionStatement:
+		c.errorf(s, "functions can only be declared at the top level")
+
+	case *parser.RecordStatement:
+		c.errorf(s, "records can only be declared at the top level")
+
+	case *parser.ReturnStatement:
+		c.checkReturn(s)
+
+	case *parser.AssignStatement:
+		if _, ok := s.Target.(*parser.Identifier); ok {
+			c.pop = s.Value
+		}
+		c.checkAssign(s)
+
+	case *parser.CompoundAssignStatement:
+		c.checkCompoundAssign(s)
+
+	case *parser.IncDecStatement:
+		if t := c.target(s.Target, true); t != nil && !t.IsNumeric() {
+			c.errorf(s, "operator %s is not defined on %s", s.Operator, t)
+		}
+
+	case *parser.ExpressionStatement:
+		c.pop = s.Expression
+		c.checkExpression(s.Expression)
+
+	case *parser.PrintStatement:
+		for _, v := range s.Values {
+			c.value(v)
+		}
+	}
+}
+
+// checkForIn checks a for-in loop. Over an array the variables are the index
+// and the element, or just the element; over a range the one variable is the
+// number. Like the counter of a C-style loop, that number counts as used.
+func (c *Checker) checkForIn(s *parser.ForInStatement) {
+	var index, value *Type
+	rng, isRange := s.Iterable.(*parser.RangeExpression)
+	if isRange {
+		for _, bound := range []parser.Expression{rng.From, rng.To} {
+			if t := c.value(bound); t != nil && t.Kind != Int {
+				c.errorf(bound, "range bound %s is %s, not int", bound.String(), t)
+			}
+		}
+		value = IntType
+		if s.Index != nil {
+			c.errorf(s.Index, "a range has one loop variable, not two").
+				WithHelp("write `for %s in %s`", s.Value.Value, rng.String())
+		}
+	} else if t := c.value(s.Iterable); t != nil {
+		if t.Kind != Array {
+			c.errorf(s.Iterable, "cannot loop over %s (type %s)", s.Iterable.String(), t)
+		} else {
+			index, value = IntType, t.Elem
+		}
+	}
+	// The elements of a const array are as fixed as the array itself
+	var shared *symbol
+	if holdsArray(value, nil) {
+		shared = c.shares(s.Iterable)
+	}
+
+	c.openScope(loopScope)
+	if s.Index != nil {
+		c.declareLoopVariable(s.Index, index, false)
+	}
+	if sym := c.declareLoopVariable(s.Value, value, isRange); sym != nil {
+		sym.shares = shared
+	}
+	c.checkLoop(s.Label, s.Body)
+	c.closeScope()
+}
+
+// checkLoop checks the body of a loop with the given label, or nil. Like in
+// Go, a label must be unique in its function and be used by a break or
+// continue.
+func (c *Checker) checkLoop(label *parser.Identifier, body []parser.Statement) {
+	l := &loop{label: label}
+	if label != nil {
+		c.checkName(label, label.Value, true)
+		if prev := c.labels[label.Value]; prev != nil {
+			c.errorf(label, "label %s is already defined", label.Value).
+				WithNote("%s was first defined at %s", label.Value, prev.Pos())
+		}
+		c.labels[label.Value] = label
+	}
+
+	c.loops = append(c.loops, l)
+	c.checkBlock(body)
+	c.loops = c.loops[:len(c.loops)-1]
+
+	if label != nil && !l.labelUsed {
+		c.errorf(label, "label %s defined and not used", label.Value).
+			WithHelp("remove the label")
+	}
+}
+
+// checkBranch checks that break and continue are inside the loop they leave.
+func (c *Checker) checkBranch(s *parser.BranchStatement) {
+	if len(c.loops) == 0 {
+		c.errorf(s, "%s outside of a loop", s.Keyword)
+		return
+	}
+	if s.Label == nil {
+		return
+	}
+
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if l := c.loops[i]; l.label != nil && l.label.Value == s.Label.Value {
+			l.labelUsed = true
+			return
+		}
+	}
+	if prev := c.labels[s.Label.Value]; prev != nil {
+		c.errorf(s.Label, "invalid %s label %s", s.Keyword, s.Label.Value).
+			WithNote("%s labels a loop at %s that does not contain this %s", s.Label.Value, prev.Pos(), s.Keyword)
+		return
+	}
+	c.errorf(s.Label, "undefined label %s", s.Label.Value)
+}
+
+// declareLoopVariable declares a for-in variable and returns its symbol,
+// unless it is the blank name `_` that discards the value.
+func (c *Checker) declareLoopVariable(ident *parser.Identifier, typ *Type, used bool) *symbol {
+	if ident.Value == "_" {
+		return nil
+	}
+	c.checkName(ident, ident.Value, false)
+	if typ != nil {
+		c.info.Types[ident] = typ
+	}
+	sym := &symbol{
+		name: ident.Value,
+		kind: variableSymbol,
+		pos:  ident.Pos(),
+		end:  ident.End(),
+		typ:  typ,
+		used: used,
+	}
+	c.scope.declare(sym)
+	return sym
+}
+
+func (c *Checker) checkVar(s *parser.VarStatement) {
+	var declared *Type
+	if s.Type != nil {
+		declared = c.resolveType(s.Type)
+	}
+	expected := declared
+	if sym := c.scope.lookup(s.Name); expected == nil && sym != nil {
+		expected = sym.typ
+	}
+	c.bind(s, s.Name, declared, s.Value, c.valueFor(s.Value, expected))
+}
+
+// checkConst checks `const name = value`. It always declares name, and the
+// checker rejects every later assignment to it. A value known at compile time
+// makes it a Go constant.
+func (c *Checker) checkConst(s *parser.ConstStatement) {
+	var declared *Type
+	if s.Type != nil {
+		declared = c.resolveType(s.Type)
+	}
+	typ := c.valueFor(s.Value, declared)
+
+	c.checkName(s, s.Name, false)
+	if prev := c.scope.symbols[s.Name]; prev != nil {
+		c.errorf(s, "%s is already declared in this block", s.Name).
+			WithNote("%s was declared at %s", s.Name, prev.pos)
+	}
+	if declared != nil && typ != nil && !c.convert(s.Value, typ, declared) {
+		c.errorf(s, "cannot use %s value as %s in declaration of %s", typ, declared, s.Name)
+	}
+	if declared == nil {
+		declared = typ
+	}
+
+	constant := declared != nil && declared.Kind != Array && c.isConstant(s.Value)
+	if declared != nil {
+		c.info.Decls[s] = declared
+	}
+	if constant {
+		c.info.Consts[s] = true
+	}
+	c.scope.declare(&symbol{
+		name:      s.Name,
+		kind:      variableSymbol,
+		pos:       s.Pos(),
+		end:       s.End(),
+		typ:       declared,
+		immutable: true,
+		constant:  constant,
+		value:     c.constValue(s.Value),
+		decl:      s,
+	})
+}
+
+func (c *Checker) checkArray(s *parser.ArrayStatement) {
+	var elem *Type
+	if s.Type != nil {
+		if t := c.resolveType(s.Type); t != nil {
+			if t.Kind != Array {
+				c.errorf(s.Type, "lazyArray %s needs an array type, not %s", s.Name, t)
+			} else {
+				elem = t.Elem
+			}
+		}
+	}
+
+	if s.Type == nil {
+		elem = c.elements(s.Values, nil, s.Name)
+		if elem == nil && len(s.Values) == 0 {
+			c.errorf(s, "cannot infer the element type of empty array %s", s.Name).
+				WithHelp("add a type annotation: lazyArray %s []int = []", s.Name)
+		}
+	} else {
+		c.elements(s.Values, elem, s.Name)
+	}
+	for _, v := range s.Values {
+		c.checkShared(v, "store %s in %s", v.String(), s.Name)
+	}
+
+	var typ *Type
+	if elem != nil {
+		typ = ArrayOf(elem)
+	}
+	c.bind(s, s.Name, typ, nil, typ)
+}
+
+// elements checks the elements of an array and returns their type. elem is
+// the annotated element type, or nil to infer it: then the first element
+// decides, widened to float if any element is a float and the rest are ints.
+// what names the array in error messages.
+func (c *Checker) elements(values []parser.Expression, elem *Type, what string) *Type {
+	types := make([]*Type, len(values))
+	for i, v := range values {
+		types[i] = c.valueFor(v, elem)
+	}
+
+	if elem == nil {
+		for _, t := range types {
+			if t == nil {
+				continue
+			}
+			if elem == nil || (elem.Kind == Int && t.Kind == Float) {
+				elem = t
+			}
+		}
+	}
+
+	if elem != nil {
+		for i, v := range values {
+			if types[i] != nil && !c.convert(v, types[i], elem) {
+				c.errorf(v, "cannot use %s (type %s) as %s element of %s", v.String(), types[i], elem, what)
+			}
+		}
+	}
+	return elem
+}
+
+// bind handles `lazy name = ...`: it assigns to name if it is visible and
+// declares it in the current scope otherwise, matching the code generator.
+// declared is the annotated type or nil, value the bound expression (nil for
+// array literals, which check their own elements) and typ its type.
+func (c *Checker) bind(stmt parser.Statement, name string, declared *Type, value parser.Expression, typ *Type) {
+	if sym := c.scope.lookup(name); sym != nil {
+		if declared != nil && sym.typ != nil && !Identical(declared, sym.typ) {
+			c.errorf(stmt, "%s is already declared with type %s", name, sym.typ).
+				WithNote("%s was declared at %s", name, sym.pos)
+		}
+		c.assign(stmt, name, value, typ)
+		if sym.typ != nil {
+			c.info.Decls[stmt] = sym.typ
+		}
+		return
+	}
+
+	c.checkName(stmt, name, false)
+	if declared != nil && typ != nil && !c.convert(value, typ, declared) {
+		c.errorf(stmt, "cannot use %s value as %s in declaration of %s", typ, declared, name)
+	}
+	c.checkShared(value, "store %s in %s", value, name)
+	if declared == nil {
+		declared = typ
+	}
+	if declared != nil {
+		c.info.Decls[stmt] = declared
+	}
+	c.scope.declare(&symbol{name: name, kind: variableSymbol, pos: stmt.Pos(), end: stmt.End(), typ: declared})
+}
+
+// assign checks an assignment of value, of type typ, to an existing name.
+func (c *Checker) assign(node parser.Node, name string, value parser.Expression, typ *Type) {
+	sym := c.scope.lookup(name)
+	switch {
+	case sym == nil:
+		c.errorf(node, "assignment to undeclared variable %s", name).
+			WithHelp("declare it first with `lazy %s = ...`", name)
+	case sym.kind == functionSymbol:
+		c.errorf(node, "cannot assign to function %s", name)
+	case sym.kind == recordSymbol:
+		c.errorf(node, "cannot assign to record %s", name)
+	case sym.immutable:
+		c.errorf(node, "cannot assign to %s %s", sym.describe(), name).
+			WithNote("%s is declared with %s at %s", name, sym.keyword(), sym.pos)
+	case sym.typ != nil && typ != nil && !c.convert(value, typ, sym.typ):
+		d := c.errorf(node, "cannot assign %s value to %s (type %s)", typ, name, sym.typ).
+			WithNote("%s was declared at %s", name, sym.pos)
+		if typ.Kind == Float && sym.typ.Kind == Int {
+			d.WithHelp("convert it explicitly with int(...), which truncates")
+		}
+	default:
+		c.modify(node, sym)
+		c.checkShared(value, "assign %s to %s", value, name)
+	}
+}
+
+// target checks the left side of an assignment and returns its type. Only
+// variables and array elements can be assigned to. read tells whether the
+// assignment also reads the old value, as `x += 1` does, which counts as a use.
+func (c *Checker) target(expr parser.Expression, read bool) *Type {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		sym := c.scope.lookup(e.Value)
+		switch {
+		case sym == nil:
+			c.errorf(e, "assignment to undeclared variable %s", e.Value).
+				WithHelp("declare it first with `lazy %s = ...`", e.Value)
+			return nil
+		case sym.kind == functionSymbol:
+			c.errorf(e, "cannot assign to function %s", e.Value)
+			return nil
+		case sym.kind == recordSymbol:
+			c.errorf(e, "cannot assign to record %s", e.Value)
+			return nil
+		}
+		if read {
+			sym.used = true
+		}
+		if sym.immutable {
+			c.errorf(e, "cannot assign to %s %s", sym.describe(), e.Value).
+				WithNote("%s is declared with %s at %s", e.Value, sym.keyword(), sym.pos)
+			return nil
+		}
+		c.modify(e, sym)
+		if sym.typ != nil {
+			c.info.Types[e] = sym.typ
+		}
+		return sym.typ
+	case *parser.IndexExpression, *parser.FieldExpression:
+		// The elements of a const array and the fields of a const record
+		// are fixed as well
+		if ident := rootIdentifier(e); ident != nil {
+			if sym := c.scope.lookup(ident.Value); sym != nil && sym.immutable {
+				c.value(e)
+				c.errorf(e, "cannot modify %s, %s is a %s", e.String(), ident.Value, sym.describe()).
+					WithNote("%s is declared with %s at %s", ident.Value, sym.keyword(), sym.pos)
+				return nil
+			} else if sym != nil && sym.kind != functionSymbol {
+				c.modify(e, sym)
+			}
+		}
+		typ := c.value(e)
+		if !addressable(e) {
+			c.errorf(e, "cannot assign to %s, it is a field of a temporary value", e.String()).
+				WithHelp("store the record in a variable first")
+			return nil
+		}
+		if sym := c.shares(elementArray(e)); sym != nil {
+			c.errorf(e, "cannot modify %s, it is an element of %s %s", e.String(), sym.describe(), sym.name).
+				WithNote("%s is declared with %s at %s", sym.name, sym.keyword(), sym.pos)
+			return nil
+		}
+		return typ
+	default:
+		c.errorf(expr, "cannot assign to %s", expr.String())
+		return nil
+	}
+}
+
+// addressable reports whether a field or element can be assigned to. Go only
+// allows it for the fields of a variable or of an array element: the record
+// returned by a call or built by a construction is a temporary copy.
+func addressable(expr parser.Expression) bool {
+	for {
+		switch e := expr.(type) {
+		case *parser.FieldExpression:
+			expr = e.Record
+		case *parser.IndexExpression, *parser.Identifier:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// elementArray returns the array that the assignment target expr is an
+// element of, as grid[i] in grid[i][j] or pts in pts[0].x, or nil if expr
+// only assigns to fields of a variable.
+func elementArray(expr parser.Expression) parser.Expression {
+	for {
+		switch e := expr.(type) {
+		case *parser.IndexExpression:
+			return e.Array
+		case *parser.FieldExpression:
+			expr = e.Record
+		default:
+			return nil
+		}
+	}
+}
+
+// checkAssign checks `target = value`. Unlike `lazy`, it never declares a
+// name, and storing a value does not count as a use of the variable.
+func (c *Checker) checkAssign(s *parser.AssignStatement) {
+	target := c.target(s.Target, false)
+	value := c.valueFor(s.Value, target)
+	if target == nil || value == nil {
+		return
+	}
+	if c.convert(s.Value, value, target) {
+		c.checkShared(s.Value, "assign %s to %s", s.Value.String(), s.Target.String())
+		return
+	}
+
+	d := c.errorf(s, "cannot assign %s value to %s (type %s)", value, s.Target.String(), target)
+	if value.Kind == Float && target.Kind == Int {
+		d.WithHelp("convert it explicitly with int(...), which truncates")
+	}
+}
+
+// checkCompoundAssign checks `target op= value`, which must be valid as
+// `target = target op value` without changing the type of target.
+func (c *Checker) checkCompoundAssign(s *parser.CompoundAssignStatement) {
+	target := c.target(s.Target, true)
+	value := c.value(s.Value)
+	if target == nil || value == nil {
+		return
+	}
+
+	switch s.Operator {
+	case "+=", "-=", "*=", "/=":
+		if s.Operator == "+=" && target.Kind == String && value.Kind == String {
+			return
+		}
+		if !target.IsNumeric() || !value.IsNumeric() {
+			c.errorf(s, "operator %s is not defined on %s and %s", s.Operator, target, value)
+			return
+		}
+		if !c.convert(s.Value, value, target) {
+			c.errorf(s, "cannot use %s value in %s on %s (type %s)", value, s.Operator, s.Target.String(), target).
+				WithHelp("convert it explicitly with int(...), which truncates")
+		}
+		if s.Operator == "/=" {
+			c.checkDivisor(s.Value)
+		}
+	case "%=":
+		if target.Kind != Int || value.Kind != Int {
+			c.errorf(s, "operator %s needs int operands, got %s and %s", s.Operator, target, value)
+			return
+		}
+		c.checkDivisor(s.Value)
+	default:
+		c.errorf(s, "unknown operator %s", s.Operator)
+	}
+}
+
+func (c *Checker) checkCondition(expr parser.Expression) {
+	if t := c.value(expr); t != nil && t.Kind != Bool {
+		c.errorf(expr, "condition %s is %s, not bool", expr.String(), t)
+	}
+}
+
+func (c *Checker) checkReturn(s *parser.ReturnStatement) {
+	if c.function == nil {
+		c.errorf(s, "return outside of a function")
+		if s.Value != nil {
+			c.value(s.Value)
+		}
+		return
+	}
+
+	result := c.function.sig.Result
+	switch {
+	case s.Value == nil:
+		if result != nil && result != VoidType {
+			c.errorf(s, "missing return value, %s returns %s", c.function.name, result)
+		}
+	case result == VoidType:
+		c.value(s.Value)
+		c.errorf(s.Value, "%s does not return a value", c.function.name)
+	default:
+		t := c.valueFor(s.Value, result)
+		if t != nil && result != nil && !c.convert(s.Value, t, result) {
+			c.errorf(s.Value, "cannot return %s value from %s, which returns %s", t, c.function.name, result)
+		}
+		c.checkShared(s.Value, "return %s from %s", s.Value.String(), c.function.name)
+	}
+}
+
+// value checks an expression that must produce a value and returns its type.
+func (c *Checker) value(expr parser.Expression) *Type {
+	t := c.checkExpression(expr)
+	if t == VoidType {
+		c.errorf(expr, "%s does not return a value", expr.String())
+		return nil
+	}
+	return t
+}
+
+// valueFor is like value for an expression stored as type expected, or nil
+// if that is unknown. An array literal takes its element type from expected,
+// so that `[]` and nested empty arrays need no annotation of their own.
+func (c *Checker) valueFor(expr parser.Expression, expected *Type) *Type {
+	lit, ok := expr.(*parser.ArrayLiteral)
+	if !ok || expected == nil || expected.Kind != Array {
+		return c.value(expr)
+	}
+
+	t := ArrayOf(c.elements(lit.Elements, expected.Elem, "array literal"))
+	c.info.Types[lit] = t
+	return t
+}
+
+// checkExpression returns the type of expr, or nil if it has errors that were
+// already reported.
+func (c *Checker) checkExpression(expr parser.Expression) *Type {
+	t := c.typeOf(expr)
+	if t != nil {
+		c.info.Types[expr] = t
+	}
+	return t
+}
+
+func (c *Checker) typeOf(expr parser.Expression) *Type {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		sym := c.resolve(e)
+		if sym == nil {
+			return nil
+		}
+		if sym.kind == functionSymbol {
+			c.errorf(e, "function %s used as a value", e.Value).
+				WithHelp("call it with %s(...)", e.Value)
+			return nil
+		}
+		if sym.kind == recordSymbol {
+			c.errorf(e, "record %s used as a value", e.Value).
+				WithHelp("construct one with %s(...)", e.Value)
+			return nil
+		}
+		if sym.lazy {
+			c.info.Lazy[e] = true
+		}
+		return sym.typ
+
+	case *parser.IntegerLiteral:
+		return IntType
+
+	case *parser.FloatLiteral:
+		return FloatType
+
+	case *parser.StringLiteral:
+		return StringType
+
+	case *parser.BooleanLiteral:
+		return BoolType
+
+	case *parser.PrefixExpression:
+		return c.checkPrefix(e)
+
+	case *parser.ArrayLiteral:
+		elem := c.elements(e.Elements, nil, "array literal")
+		if elem == nil {
+			if len(e.Elements) == 0 {
+				c.errorf(e, "cannot infer the element type of empty array literal").
+					WithHelp("add a type annotation, such as `lazy xs []int = []`")
+			}
+			return nil
+		}
+		return ArrayOf(elem)
+
+	case *parser.CallExpression:
+		return c.checkCall(e)
+
+	case *parser.InfixExpression:
+		return c.checkInfix(e)
+
+	case *parser.FieldExpression:
+		return c.checkField(e)
+
+	case *parser.IndexExpression:
+		array := c.value(e.Array)
+		index := c.value(e.Index)
+		if index != nil && index.Kind != Int {
+			c.errorf(e.Index, "array index %s is %s, not int", e.Index.String(), index)
+		}
+		if array == nil {
+			return nil
+		}
+		if array.Kind != Array {
+			c.errorf(e.Array, "cannot index %s (type %s)", e.Array.String(), array)
+			return nil
+		}
+		return array.Elem
+	}
+	return nil
+}
+
+func (c *Checker) checkCall(e *parser.CallExpression) *Type {
+	ident, ok := e.Function.(*parser.Identifier)
+	if !ok {
+		c.errorf(e.Function, "%s is not a function", e.Function.String())
+		return nil
+	}
+
+	if isBuiltin(ident.Value) && c.scope.lookup(ident.Value) == nil {
+		return c.checkBuiltin(ident.Value, e)
+	}
+
+	sym := c.resolve(ident)
+	if sym != nil && sym.kind == recordSymbol {
+		return c.checkConstruction(sym.typ, e)
+	}
+
+	// Parameter types give array literal arguments their element type
+	var params []*Type
+	if sym != nil && sym.kind == functionSymbol && len(sym.sig.Params) == len(e.Arguments) {
+		params = sym.sig.Params
+	}
+	args := make([]*Type, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		var expected *Type
+		if params != nil {
+			expected = params[i]
+		}
+		args[i] = c.valueFor(arg, expected)
+	}
+	if sym == nil {
+		return nil
+	}
+	if sym.kind != functionSymbol {
+		c.errorf(ident, "%s is not a function", ident.Value)
+		return nil
+	}
+
+	c.checkWrites(ident.Value, e)
+
+	sig := sym.sig
+	if len(args) != len(sig.Params) {
+		c.errorf(e, "wrong number of arguments to %s: want %d, got %d", ident.Value, len(sig.Params), len(args))
+		return sig.Result
+	}
+	for i, arg := range e.Arguments {
+		param := sig.Params[i]
+		if args[i] != nil && param != nil && !c.convert(arg, args[i], param) {
+			c.errorf(arg, "cannot use %s (type %s) as %s argument to %s", arg.String(), args[i], param, ident.Value)
+		}
+	}
+	return sig.Result
+}
+
+func (c *Checker) checkPrefix(e *parser.PrefixExpression) *Type {
+	right := c.value(e.Right)
+	if right == nil {
+		return nil
+	}
+
+	switch e.Operator {
+	case "!":
+		if right.Kind != Bool {
+			c.errorf(e, "operator ! is not defined on %s", right)
+			return nil
+		}
+		return BoolType
+	case "-":
+		if !right.IsNumeric() {
+			c.errorf(e, "operator - is not defined on %s", right)
+			return nil
+		}
+		c.checkOverflow(e, e.Right)
+		return right
+	}
+
+	c.errorf(e, "unknown operator %s", e.Operator)
+	return nil
+}
+
+func (c *Checker) checkInfix(e *parser.InfixExpression) *Type {
+	left := c.value(e.Left)
+	right := c.value(e.Right)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	switch e.Operator {
+	case "&&", "||":
+		// Both are short-circuiting, like in Go
+		if left.Kind != Bool || right.Kind != Bool {
+			c.errorf(e, "operator %s needs bool operands, got %s and %s", e.Operator, left, right)
+			return nil
+		}
+		return BoolType
+
+	case "+", "-", "*", "/":
+		if e.Operator == "+" && left.Kind == String && right.Kind == String {
+			return StringType
+		}
+		if left.IsNumeric() && right.IsNumeric() {
+			if e.Operator == "/" {
+				c.checkDivisor(e.Right)
+			}
+			typ := c.promote(e, left, right)
+			c.checkOverflow(e, e.Left, e.Right)
+			return typ
+		}
+		c.errorf(e, "operator %s is not defined on %s and %s", e.Operator, left, right)
+		return nil
+
+	case "**":
+		if left.IsNumeric() && right.IsNumeric() {
+			return c.promote(e, left, right)
+		}
+		c.errorf(e, "operator %s is not defined on %s and %s", e.Operator, left, right)
+		return nil
+
+	case "%", "&", "|", "^", "<<", ">>":
+		if left.Kind != Int || right.Kind != Int {
+			c.errorf(e, "operator %s needs int operands, got %s and %s", e.Operator, left, right)
+			return nil
+		}
+		switch e.Operator {
+		case "%":
+			c.checkDivisor(e.Right)
+		case "<<", ">>":
+			c.checkShift(e)
+		}
+		c.checkOverflow(e, e.Left, e.Right)
+		return IntType
+
+	case "<", ">", "<=", ">=":
+		if left.Kind == String && right.Kind == String {
+			return BoolType
+		}
+		if left.IsNumeric() && right.IsNumeric() {
+			c.promote(e, left, right)
+			return BoolType
+		}
+		c.errorf(e, "operator %s is not defined on %s and %s", e.Operator, left, right)
+		return nil
+
+	case "==", "!=":
+		if left.Kind == Array || right.Kind == Array {
+			c.errorf(e, "arrays cannot be compared with %s", e.Operator)
+			return nil
+		}
+		if !left.Comparable() {
+			c.errorf(e, "%s cannot be compared with %s, it has an array field", left, e.Operator)
+			return nil
+		}
+		if left.IsNumeric() && right.IsNumeric() {
+			c.promote(e, left, right)
+			return BoolType
+		}
+		if !Identical(left, right) {
+			c.errorf(e, "mismatched types %s and %s in %s", left, right, e.String())
+			return nil
+		}
+		return BoolType
+	}
+
+	c.errorf(e, "unknown operator %s", e.Operator)
+	return nil
+}
+
+// promote gives mixed int and float operands a common type: the int side is
+// converted to float. It returns the type of the arithmetic result.
+func (c *Checker) promote(e *parser.InfixExpression, left, right *Type) *Type {
+	switch {
+	case left.Kind == right.Kind:
+		return left
+	case left.Kind == Int:
+		c.info.Promoted[e.Left] = true
+	default:
+		c.info.Promoted[e.Right] = true
+	}
+	return FloatType
+}
+
+// convert reports whether expr, of type typ, can be stored in a variable of
+// type target. Ints widen to float implicitly; the other direction needs an
+// explicit int(...) because it loses the fraction.
+func (c *Checker) convert(expr parser.Expression, typ, target *Type) bool {
+	if Identical(typ, target) {
+		return true
+	}
+	if typ.Kind == Int && target.Kind == Float && expr != nil {
+		c.info.Promoted[expr] = true
+		return true
+	}
+	return false
+}
+
+// resolve looks up the declaration of ident and marks it as used.
+func (c *Checker) resolve(ident *parser.Identifier) *symbol {
+	sym := c.scope.lookup(ident.Value)
+	if sym != nil {
+		sym.used = true
+		c.capture(sym)
+		return sym
+	}
+
+	for _, pos := range c.declared[ident.Value] {
+		if pos.Offset > ident.Pos().Offset {
+			c.errorf(ident, "%s used before it is declared", ident.Value).
+				WithNote("%s is declared at %s", ident.Value, pos)
+			return nil
+		}
+	}
+	c.errorf(ident, "undefined: %s", ident.Value)
+	return nil
+}
+
+// collectDeclarations returns the positions of every `lazy` and `lazyArray`
+// declaration in stmts and the blocks nested in them, to tell a use before
+// declaration apart from a name that is never declared.
+func collectDeclarations(stmts []parser.Statement) map[string][]lexer.Position {
+	declared := make(map[string][]lexer.Position)
+
+	var walk func(stmts []parser.Statement)
+	walk = func(stmts []parser.Statement) {
+		for _, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *parser.VarStatement:
+				declared[s.Name] = append(declared[s.Name], s.Pos())
+			case *parser.ConstStatement:
+				declared[s.Name] = append(declared[s.Name], s.Pos())
+			case *parser.LazyStatement:
+				declared[s.Name] = append(declared[s.Name], s.Pos())
+			case *parser.ArrayStatement:
+				declared[s.Name] = append(declared[s.Name], s.Pos())
+			case *parser.ForStatement:
+				if s.Init != nil {
+					walk([]parser.Statement{s.Init})
+				}
+				walk(s.Body)
+			case *parser.ForInStatement:
+				walk(s.Body)
+			case *parser.WhileStatement:
+				walk(s.Body)
+			case *parser.IfStatement:
+				walk(s.Consequence)
+				walk(s.Alternative)
+			case *parser.MatchStatement:
+				for _, arm := range s.Arms {
+					walk(arm.Body)
+				}
+			}
+		}
+	}
+	walk(stmts)
+
+	return declared
+}
@@ -0,0 +1,206 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/diagnostics"
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// checkTest is a program and the error the checker reports on it, or "" if
+// it has none. Warnings are ignored.
+type checkTest struct {
+	name  string
+	input string
+	want  string
+}
+
+// check parses and checks input and returns the messages of the diagnostics
+// with the given severity.
+func check(t *testing.T, input string, severity diagnostics.Severity) []string {
+	t.Helper()
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if len(l.Errors()) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("cannot parse %q", input)
+	}
+
+	var msgs []string
+	for _, d := range NewChecker().Check(program) {
+		if d.Severity == severity {
+			msgs = append(msgs, d.Message)
+		}
+	}
+	return msgs
+}
+
+func runCheckTests(t *testing.T, tests []checkTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := check(t, tt.input, diagnostics.Error)
+			switch {
+			case tt.want == "" && len(errs) > 0:
+				t.Errorf("unexpected errors: %q", errs)
+			case tt.want != "" && len(errs) == 0:
+				t.Errorf("no errors, want %q", tt.want)
+			case tt.want != "" && !strings.Contains(strings.Join(errs, "\n"), tt.want):
+				t.Errorf("errors are %q, want %q", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"distinct", "lazy x = 2\nmatch x {\n1 => lazyPrint(1)\n2 => lazyPrint(2)\n_ => lazyPrint(0)\n}", ""},
+		{"duplicate literal", "lazy x = 2\nmatch x {\n1 => lazyPrint(1)\n1 => lazyPrint(2)\n_ => lazyPrint(0)\n}", "duplicate pattern 1"},
+		{"duplicate constants", "const a = 1\nconst b = 1\nlazy x = 2\nmatch x {\na => lazyPrint(1)\nb => lazyPrint(2)\n_ => lazyPrint(0)\n}", "duplicate pattern b"},
+		{"duplicate expression", "lazy x = 2\nmatch x {\n1 + 1 => lazyPrint(1)\n2 => lazyPrint(2)\n_ => lazyPrint(0)\n}", "duplicate pattern 2"},
+		{"int and float", "lazy x = 2.5\nmatch x {\n2 => lazyPrint(1)\n2.0 => lazyPrint(2)\n_ => lazyPrint(0)\n}", "duplicate pattern 2.0"},
+		{"duplicate string", "lazy s = \"hi\"\nmatch s {\n\"h\" + \"i\" => lazyPrint(1)\n\"hi\" => lazyPrint(2)\n_ => lazyPrint(0)\n}", "duplicate pattern \"hi\""},
+		{"modulo", "lazy x = 2\nmatch x {\n7 % 2 => lazyPrint(1)\n3 => lazyPrint(2)\n_ => lazyPrint(0)\n}", ""},
+		{"variables", "lazy x = 2\nlazy y = 3\nmatch x {\ny => lazyPrint(1)\ny => lazyPrint(2)\n_ => lazyPrint(0)\n}", "duplicate pattern y"},
+		{"wildcard not last", "lazy x = 2\nmatch x {\n_ => lazyPrint(0)\n1 => lazyPrint(1)\n}", "unreachable match arm"},
+	})
+}
+
+func TestBuiltins(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"len", "lazy a = [1, 2]\nlazyPrint(len(a), len(\"hi\"))", ""},
+		{"len of int", "lazyPrint(len(1))", "cannot take the length of 1"},
+		{"append converts", "lazy a = [1.5]\nlazyPrint(append(a, 1))", ""},
+		{"append wrong type", "lazy a = [1]\nlazyPrint(append(a, \"x\"))", "cannot append \"x\" (type string) to []int"},
+		{"push needs a variable", "push([1], 2)", "cannot assign to [1]"},
+		{"pop statement", "lazy a = [1, 2]\npop(a)\nlazy last = pop(a)\nlast = pop(a)\nlazyPrint(a, last)", ""},
+		{"pop in expression", "lazy a = [1, 2]\nlazyPrint(a, pop(a))", "pop(a) must be a statement"},
+		{"pop in condition", "lazy a = [1, 2]\nif pop(a) > 1 {\nlazyPrint(a)\n}", "pop(a) must be a statement"},
+		{"slice bound", "lazy a = [1, 2]\nlazyPrint(slice(a, 0, 1.5))", "slice bound 1.5 is float, not int"},
+		{"slice string", "lazyPrint(slice(\"hello\", 1, 3))", ""},
+		{"contains", "lazy a = [1, 2]\nlazyPrint(contains(a, 2), contains(\"hi\", \"h\"))", ""},
+		{"contains nested", "lazy a = [[1]]\nlazyPrint(contains(a, [1]))", "arrays of arrays cannot be compared"},
+	})
+}
+
+func TestNames(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"function main", "lazyFn main() {\n}", "cannot declare a function named main"},
+		{"function init", "lazyFn init() {\n}", "cannot declare a function named init"},
+		{"record init", "record init { v int }", "cannot declare a record named init"},
+		{"variable init", "lazy init = 1\nlazyPrint(init)", ""},
+		{"builtin", "lazyFn len() {\n}", "cannot declare a function named len"},
+		{"go keyword", "lazy chan = 1\nlazyPrint(chan)", "cannot use chan as a name, it is a keyword in Go"},
+		{"go package", "lazy fmt = 1\nlazyPrint(fmt)", "cannot use fmt as a name, the generated Go code needs it"},
+		{"helper", "lazyFn lazyPop() {\n}", "cannot use lazyPop as a name"},
+		{"keyword field", "record P { type int }", "cannot use type as a name"},
+		{"package field", "record P { fmt int }\nlazyPrint(P(1))", ""},
+	})
+}
+
+func TestNumbers(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"mixed arithmetic", "lazy x = 1 + 2.5\nlazy y float = 2\nlazyPrint(x, y)", ""},
+		{"float to int", "lazy x int = 2.5\nlazyPrint(x)", "cannot use float value as int"},
+		{"assign float to int", "lazy x = 1\nx = 2.5", "cannot assign float value to x (type int)"},
+		{"modulo on floats", "lazyPrint(2.5 % 2)", "operator % needs int operands"},
+		{"division by zero", "lazy x = 1\nlazyPrint(x / 0)", "division by zero"},
+		{"division by constant zero", "const zero = 0\nlazy x = 1\nlazyPrint(x % (zero * 2))", "division by zero"},
+		{"max int", "lazyPrint(9223372036854775807 - 1 + 1)", ""},
+		{"overflow", "lazyPrint(9223372036854775807 + 1)", "constant (9223372036854775807 + 1) overflows int"},
+		{"overflow through constant", "const big = 4611686018427387904\nlazyPrint(big * 2)", "constant (big * 2) overflows int"},
+		{"overflow by shift", "lazyPrint(1 << 70)", "constant (1 << 70) overflows int"},
+		{"float overflow", "lazyPrint(1e308 * 10.0)", "overflows float"},
+		{"shift", "lazy x = 1\nlazyPrint(x << 70, 1 << 62)", ""},
+		{"negative shift", "lazy x = 1\nlazyPrint(x << -1)", "invalid negative shift count (-1)"},
+		{"int of whole constant", "lazyPrint(int(5.0))", ""},
+		{"int of fraction", "lazyPrint(int(5.5))", "cannot convert constant 5.5 to int, it has a fraction"},
+		{"int of variable", "lazy f = 5.5\nlazyPrint(int(f))", ""},
+	})
+}
+
+func TestBranches(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"break in loop", "for i in 0..3 {\nif i == 1 {\nbreak\n}\ncontinue\n}", ""},
+		{"break outside loop", "break", "break outside of a loop"},
+		{"continue in function", "lazyFn f() {\ncontinue\n}\nf()", "continue outside of a loop"},
+		{"labeled break", "outer: for i in 0..3 {\nfor j in 0..3 {\nbreak outer\n}\n}", ""},
+		{"undefined label", "for i in 0..3 {\nbreak outer\n}", "undefined label outer"},
+		{"label of other loop", "outer: for i in 0..3 {\nbreak outer\n}\nfor j in 0..3 {\ncontinue outer\n}", "invalid continue label outer"},
+		{"unused label", "outer: for i in 0..3 {\nlazyPrint(i)\n}", "label outer defined and not used"},
+		{"duplicate label", "outer: for i in 0..3 {\nbreak outer\n}\nouter: for j in 0..3 {\nbreak outer\n}", "label outer is already defined"},
+		{"break in match", "for i in 0..3 {\nmatch i {\n1 => break\n_ => lazyPrint(i)\n}\n}", ""},
+	})
+}
+
+func TestLazyOnce(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"lazyOnce", "lazy n = 2\nlazyOnce sq = n * n\nlazyPrint(sq)", ""},
+		{"assign", "lazyOnce sq = 4\nsq = 5", "cannot assign to lazy binding sq"},
+		{"modify element", "lazyOnce xs = [1]\nxs[0] = 2", "cannot modify xs[0], xs is a lazy binding"},
+		{"append in value", "lazyFn grow(a []int) int {\nreturn len(a)\n}\nlazy xs = [1]\nlazyOnce n = len(append(xs, 2))\nlazyPrint(n, grow(xs))", ""},
+		{"writes in value", "lazyFn fill(a []int) int {\na[0] = 1\nreturn 0\n}\nlazy xs = [0]\nlazyOnce n = fill(xs)\nlazyPrint(n)", "lazyOnce n cannot modify xs"},
+		{"unused", "lazyOnce sq = 4", "declared and not used: sq"},
+	})
+}
+
+func TestLazyOnceWarnings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"modified before use", "lazy n = 2\nlazyOnce sq = n * n\nn = 3\nlazyPrint(sq)", "n is modified before lazyOnce sq is first used"},
+		{"modified after use", "lazy n = 2\nlazyOnce sq = n * n\nlazyPrint(sq)\nn = 3\nlazyPrint(n)", ""},
+		{"element modified", "lazy xs = [1]\nlazyOnce first = xs[0]\nxs[0] = 2\nlazyPrint(first)", "xs is modified before lazyOnce first is first used"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := check(t, tt.input, diagnostics.Warning)
+			switch {
+			case tt.want == "" && len(warnings) > 0:
+				t.Errorf("unexpected warnings: %q", warnings)
+			case tt.want != "" && !strings.Contains(strings.Join(warnings, "\n"), tt.want):
+				t.Errorf("warnings are %q, want %q", warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecords(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"construction", "record Point { x, y }\nlazy p = Point(1, 2)\np.x = 3\nlazyPrint(p.y)", ""},
+		{"annotated field", "record Point { x float, y }\nlazy p = Point(1, 2)\nlazyPrint(p.x + 0.5)", ""},
+		{"wrong field count", "record Point { x, y }\nlazyPrint(Point(1))", "wrong number of fields for Point: want 2, got 1"},
+		{"inferred field type", "record Point { x, y }\nlazyPrint(Point(1, 2), Point(1, \"a\"))", "cannot use \"a\" (type string) as int field y of Point"},
+		{"never constructed", "record Point { x, y }", "cannot infer the type of field x of Point, it is never constructed"},
+		{"unknown field", "record Point { x int }\nlazy p = Point(1)\nlazyPrint(p.z)", "Point has no field z"},
+		{"duplicate field", "record Point { x int, x int }", "duplicate field x in record Point"},
+		{"recursive", "record Node { next Node }", "invalid recursive record Node"},
+		{"recursive through array", "record Node { children []Node }\nlazyPrint(Node([]))", ""},
+		{"field of element", "record Point { x int }\nlazy ps = [Point(1)]\nps[0].x = 2\nlazyPrint(ps)", ""},
+		{"field of temporary", "record Point { x int }\nPoint(1).x = 2", "cannot assign to Point(1).x, it is a field of a temporary value"},
+		{"field of call", "record Point { x int }\nlazyFn origin() Point {\nreturn Point(0)\n}\norigin().x = 2", "it is a field of a temporary value"},
+		{"const record", "record Point { x int }\nconst p = Point(1)\np.x = 2", "cannot modify p.x, p is a constant"},
+		{"compare with array field", "record Bag { items []int }\nlazy b = Bag([1])\nlazyPrint(b == b)", "cannot be compared with =="},
+	})
+}
+
+func TestConst(t *testing.T) {
+	runCheckTests(t, []checkTest{
+		{"unused", "const pi = 3.14", ""},
+		{"assign", "const limit = 10\nlimit = 5", "cannot assign to constant limit"},
+		{"compound assign", "const limit = 10\nlimit += 5", "cannot assign to constant limit"},
+		{"element", "const xs = [1, 2]\nxs[0] = 9", "cannot modify xs[0], xs is a constant"},
+		{"alias", "const xs = [1, 2]\nlazy ys = xs\nlazyPrint(ys)", "cannot store xs in ys, it shares its elements with constant xs"},
+		{"copy", "const xs = [1, 2]\nlazy ys = slice(xs, 0, 2)\nys[0] = 9\nlazyPrint(ys)", ""},
+		{"nested copy", "const grid = [[1]]\nlazy g = slice(grid, 0, 1)\nlazyPrint(g)", "shares its elements with constant grid"},
+		{"function writes", "lazyFn clobber(a []int) {\na[0] = 9\n}\nconst xs = [1]\nclobber(xs)", "cannot pass xs to clobber, which modifies its elements"},
+		{"function reads", "lazyFn first(a []int) int {\nreturn a[0]\n}\nconst xs = [1]\nlazyPrint(first(xs))", ""},
+		{"loop variable", "const grid = [[1], [2]]\nfor row in grid {\nrow[0] = 7\n}", "cannot modify row[0], it is an element of constant grid"},
+		{"loop over ints", "const xs = [1, 2]\nfor x in xs {\nx = 3\nlazyPrint(x)\n}", ""},
+		{"push", "const xs = [1]\nlazy g = [[0]]\npush(g, xs)\nlazyPrint(g)", "cannot push xs to g"},
+	})
+}
@@ -0,0 +1,314 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// checkLazy checks `lazyOnce name = value`. Unlike `lazy`, the value is not
+// computed where it is declared but when name is first used, and then never
+// again. Like a constant, name always declares a new binding that cannot be
+// assigned.
+//
+// Evaluating later makes two things unpredictable, so the checker tracks
+// which variables the value reads:
+//
+//   - the value cannot modify variables itself, through push, pop or a
+//     function that writes to the elements of an array argument, since that
+//     would happen at some later use of name;
+//   - modifying a variable the value reads before name is first used gets a
+//     warning, since the value will see the new contents.
+func (c *Checker) checkLazy(s *parser.LazyStatement) {
+	var declared *Type
+	if s.Type != nil {
+		declared = c.resolveType(s.Type)
+	}
+
+	sym := &symbol{
+		name:      s.Name,
+		kind:      variableSymbol,
+		pos:       s.Pos(),
+		end:       s.End(),
+		immutable: true,
+		lazy:      true,
+	}
+	c.lazy = sym
+	typ := c.valueFor(s.Value, declared)
+	c.lazy = nil
+
+	c.checkName(s, s.Name, false)
+	if prev := c.scope.symbols[s.Name]; prev != nil {
+		c.errorf(s, "%s is already declared in this block", s.Name).
+			WithNote("%s was declared at %s", s.Name, prev.pos)
+	}
+	if declared != nil && typ != nil && !c.convert(s.Value, typ, declared) {
+		c.errorf(s, "cannot use %s value as %s in declaration of %s", typ, declared, s.Name)
+	}
+	if declared == nil {
+		declared = typ
+	}
+
+	if declared != nil {
+		c.info.Decls[s] = declared
+	}
+	sym.typ = declared
+	c.scope.declare(sym)
+}
+
+// capture records that the value of the lazyOnce binding being checked, if
+// any, reads sym.
+func (c *Checker) capture(sym *symbol) {
+	if c.lazy == nil || sym.kind == functionSymbol || sym.immutable {
+		return
+	}
+	if n := len(sym.captured); n > 0 && sym.captured[n-1] == c.lazy {
+		return
+	}
+	sym.captured = append(sym.captured, c.lazy)
+}
+
+// modify checks that the variable sym may be modified at node.
+func (c *Checker) modify(node parser.Node, sym *symbol) {
+	if c.lazy != nil {
+		c.errorf(node, "lazyOnce %s cannot modify %s", c.lazy.name, sym.name).
+			WithNote("the value of %s is computed when %s is first used, not where it is declared", c.lazy.name, c.lazy.name).
+			WithHelp("declare it with `lazy %s = ...` to compute it right away", c.lazy.name)
+		return
+	}
+
+	for _, lazy := range sym.captured {
+		if !lazy.used && lazy.typ != nil {
+			c.warnf(node, "%s is modified before lazyOnce %s is first used", sym.name, lazy.name).
+				WithNote("%s reads %s when it is first used, so it sees the new value", lazy.name, sym.name)
+		}
+	}
+}
+
+// checkWrites checks the arguments of a call of fn that fn modifies the
+// elements of. The elements of constants cannot be modified.
+func (c *Checker) checkWrites(fn string, e *parser.CallExpression) {
+	writes := c.writes[fn]
+	for i, arg := range e.Arguments {
+		if i >= len(writes) || !writes[i] {
+			continue
+		}
+		if sym := c.shares(arg); sym != nil {
+			c.errorf(arg, "cannot pass %s to %s, which modifies its elements", arg.String(), fn).
+				WithNote("the elements belong to %s %s, declared with %s at %s", sym.describe(), sym.name, sym.keyword(), sym.pos)
+			continue
+		}
+		root := rootIdentifier(arg)
+		if root == nil {
+			continue
+		}
+		if sym := c.scope.lookup(root.Value); sym != nil && sym.kind != functionSymbol {
+			c.modify(arg, sym)
+		}
+	}
+}
+
+// rootIdentifier returns the variable that expr is an element or field of,
+// as in grid[i][j] or p.x, or expr itself if it is an identifier. It returns
+// nil for other expressions.
+func rootIdentifier(expr parser.Expression) *parser.Identifier {
+	for {
+		switch e := expr.(type) {
+		case *parser.Identifier:
+			return e
+		case *parser.IndexExpression:
+			expr = e.Array
+		case *parser.FieldExpression:
+			expr = e.Record
+		default:
+			return nil
+		}
+	}
+}
+
+// shares returns the const or lazyOnce binding whose arrays expr refers to,
+// or nil. Arrays are not copied, so `lazy ys = xs` would make ys another name
+// for the elements of a const xs, and so would storing xs in an array, a
+// record or a for-in variable. Calls may return the arrays passed to them.
+func (c *Checker) shares(expr parser.Expression) *symbol {
+	if !holdsArray(c.info.Types[expr], nil) {
+		return nil
+	}
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		sym := c.scope.lookup(e.Value)
+		if sym == nil || sym.kind == functionSymbol || sym.kind == recordSymbol {
+			return nil
+		}
+		if sym.immutable {
+			return sym
+		}
+		return sym.shares
+	case *parser.IndexExpression:
+		return c.shares(e.Array)
+	case *parser.FieldExpression:
+		return c.shares(e.Record)
+	case *parser.ArrayLiteral:
+		return c.sharesAny(e.Elements)
+	case *parser.CallExpression:
+		// append and slice copy the array, but not the arrays it holds
+		if name := c.info.Builtins[e]; (name == "append" || name == "slice") && !holdsArray(c.info.Types[e].Elem, nil) {
+			return nil
+		}
+		return c.sharesAny(e.Arguments)
+	}
+	return nil
+}
+
+func (c *Checker) sharesAny(exprs []parser.Expression) *symbol {
+	for _, expr := range exprs {
+		if sym := c.shares(expr); sym != nil {
+			return sym
+		}
+	}
+	return nil
+}
+
+// checkShared reports storing value where it could be modified, as described
+// by the format and args, if it shares arrays with a constant.
+func (c *Checker) checkShared(value parser.Expression, format string, args ...interface{}) {
+	if value == nil {
+		return
+	}
+	if sym := c.shares(value); sym != nil {
+		c.errorf(value, "cannot %s, it shares its elements with %s %s", fmt.Sprintf(format, args...), sym.describe(), sym.name).
+			WithNote("%s is declared with %s at %s", sym.name, sym.keyword(), sym.pos)
+	}
+}
+
+// holdsArray reports whether a value of type t refers to arrays, which are
+// shared rather than copied when the value is.
+func holdsArray(t *Type, seen map[*RecordType]bool) bool {
+	switch {
+	case t == nil:
+		return false
+	case t.Kind == Array:
+		return true
+	case t.Kind != Record || seen[t.Record]:
+		return false
+	}
+	if seen == nil {
+		seen = make(map[*RecordType]bool)
+	}
+	seen[t.Record] = true
+	for _, f := range t.Record.Fields {
+		if holdsArray(f.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramWrites reports, for every function, which of its parameters it
+// modifies the elements of. Arrays are passed by reference, so a call such as
+// fill(nums) can change nums. Passing a parameter on to a function that
+// modifies it counts as well, and so does writing through a variable that
+// was bound to it.
+func paramWrites(functions []*parser.FunctionStatement) map[string][]bool {
+	writes := make(map[string][]bool)
+	type pass struct {
+		param int    // parameter of the caller
+		fn    string // function it is passed to
+		arg   int
+	}
+	passes := make(map[string][]pass)
+
+	for _, fn := range functions {
+		params := make(map[string]int)
+		for i, p := range fn.Parameters {
+			params[p.Name] = i
+		}
+		written := make([]bool, len(fn.Parameters))
+		writes[fn.Name] = written
+
+		// elementOf returns the parameter that expr is an element of, or -1.
+		// Records are copied when they are passed, so only writes through
+		// an array reach the caller.
+		elementOf := func(expr parser.Expression) int {
+			indexed := false
+			for {
+				switch e := expr.(type) {
+				case *parser.IndexExpression:
+					indexed = true
+					expr = e.Array
+				case *parser.FieldExpression:
+					expr = e.Record
+				case *parser.Identifier:
+					if i, ok := params[e.Value]; ok && indexed {
+						return i
+					}
+					return -1
+				default:
+					return -1
+				}
+			}
+		}
+
+		for _, stmt := range fn.Body {
+			parser.Inspect(stmt, func(node parser.Node) bool {
+				var target parser.Expression
+				switch n := node.(type) {
+				case *parser.VarStatement:
+					if root := rootIdentifier(n.Value); root != nil {
+						if i, ok := params[root.Value]; ok {
+							params[n.Name] = i
+						}
+					}
+				case *parser.ForInStatement:
+					if root := rootIdentifier(n.Iterable); root != nil {
+						if i, ok := params[root.Value]; ok {
+							params[n.Value.Value] = i
+						}
+					}
+				case *parser.AssignStatement:
+					target = n.Target
+				case *parser.CompoundAssignStatement:
+					target = n.Target
+				case *parser.IncDecStatement:
+					target = n.Target
+				case *parser.CallExpression:
+					ident, ok := n.Function.(*parser.Identifier)
+					if !ok {
+						return true
+					}
+					if (ident.Value == "push" || ident.Value == "pop") && len(n.Arguments) > 0 {
+						// Storing a grown or shrunk element back writes to
+						// the array it is in
+						target = n.Arguments[0]
+						break
+					}
+					for j, arg := range n.Arguments {
+						if root := rootIdentifier(arg); root != nil {
+							if i, ok := params[root.Value]; ok {
+								passes[fn.Name] = append(passes[fn.Name], pass{param: i, fn: ident.Value, arg: j})
+							}
+						}
+					}
+				}
+				if i := elementOf(target); i >= 0 {
+					written[i] = true
+				}
+				return true
+			})
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for caller, ps := range passes {
+			for _, p := range ps {
+				callee := writes[p.fn]
+				if !writes[caller][p.param] && p.arg < len(callee) && callee[p.arg] {
+					writes[caller][p.param] = true
+					changed = true
+				}
+			}
+		}
+	}
+	return writes
+}
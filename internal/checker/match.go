@@ -0,0 +1,84 @@
+package checker
+
+import (
+	"go/constant"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// checkMatch checks a match statement. Every pattern must be comparable
+// with the matched value, and a `_` arm, which matches everything, must come
+// last. A match that may run no arm at all gets a warning: without enums only
+// a bool can be covered completely without `_`.
+func (c *Checker) checkMatch(s *parser.MatchStatement) {
+	value := c.value(s.Value)
+	if value != nil && (value.Kind == Array || value.Kind == Record) {
+		c.errorf(s.Value, "cannot match on %s (type %s)", s.Value.String(), value)
+		value = nil
+	}
+
+	seen := make(map[string]parser.Expression)
+	var wildcard *parser.MatchArm
+	for _, arm := range s.Arms {
+		if wildcard != nil {
+			c.errorf(arm, "unreachable match arm").
+				WithNote("the `_` arm at %s matches every value", wildcard.Pos())
+		}
+		if len(arm.Patterns) == 0 && wildcard == nil {
+			wildcard = arm
+		}
+
+		for _, pattern := range arm.Patterns {
+			t := c.value(pattern)
+			if value == nil || t == nil {
+				continue
+			}
+			if !c.convert(pattern, t, value) {
+				c.errorf(pattern, "cannot match %s (type %s) against %s (type %s)", pattern.String(), t, s.Value.String(), value)
+				continue
+			}
+
+			// Go rejects duplicate constant cases, and the second could
+			// never match anyway
+			key := c.patternKey(pattern, value)
+			if first, ok := seen[key]; ok {
+				c.errorf(pattern, "duplicate pattern %s in match", pattern.String()).
+					WithNote("%s is already matched at %s", first.String(), first.Pos())
+				continue
+			}
+			seen[key] = pattern
+		}
+
+		c.checkBlock(arm.Body)
+	}
+
+	if wildcard != nil || value == nil {
+		return
+	}
+	if value.Kind == Bool {
+		for _, missing := range []string{"true", "false"} {
+			if _, ok := seen[missing]; !ok {
+				c.warnf(s, "match on %s is not exhaustive: %s is not matched", s.Value.String(), missing).
+					WithHelp("add a `%s => ...` or `_ => ...` arm", missing)
+			}
+		}
+		return
+	}
+	c.warnf(s, "match on %s is not exhaustive: some %s values are not matched", s.Value.String(), value).
+		WithHelp("add a `_ => ...` arm for the remaining values")
+}
+
+// patternKey returns the same key for patterns with equal values when
+// matched against a value of type typ. Constant patterns are compared by
+// value, so `1 + 1` and a constant two are the same as `2`; other patterns
+// by their text.
+func (c *Checker) patternKey(pattern parser.Expression, typ *Type) string {
+	v := c.constValue(pattern)
+	if v == nil {
+		return pattern.String()
+	}
+	if typ.Kind == Float {
+		v = constant.ToFloat(v)
+	}
+	return v.ExactString()
+}
@@ -0,0 +1,187 @@
+package checker
+
+import (
+	"strings"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// declareRecords declares the records of a program. Like functions they can
+// be used before they are declared, and their fields can refer to each other,
+// so all names are declared before any field type is resolved.
+func (c *Checker) declareRecords(records []*parser.RecordStatement) {
+	var declared []*RecordType
+	for _, s := range records {
+		switch s.Name {
+		case "main", "init", "int", "float", "bool", "string":
+			c.errorf(s, "cannot declare a record named %s", s.Name).
+				WithHelp("choose another name")
+			continue
+		}
+		if isBuiltin(s.Name) {
+			c.errorf(s, "cannot declare a record named %s, it is a built-in function", s.Name)
+			continue
+		}
+		c.checkName(s, s.Name, false)
+		if prev := c.universe.symbols[s.Name]; prev != nil {
+			c.errorf(s, "record %s is already declared", s.Name).
+				WithNote("%s was first declared at %s", s.Name, prev.pos)
+			continue
+		}
+
+		rec := &RecordType{Name: s.Name, decl: s}
+		typ := &Type{Kind: Record, Record: rec}
+		c.info.Decls[s] = typ
+		c.universe.declare(&symbol{
+			name: s.Name,
+			kind: recordSymbol,
+			pos:  s.Pos(),
+			end:  s.End(),
+			typ:  typ,
+			used: true,
+		})
+		declared = append(declared, rec)
+	}
+
+	for _, rec := range declared {
+		for _, f := range rec.decl.Fields {
+			if rec.Field(f.Name) != nil {
+				c.errorf(f, "duplicate field %s in record %s", f.Name, rec.Name)
+				continue
+			}
+			c.checkName(f, f.Name, true)
+			field := &Field{Name: f.Name, decl: f}
+			if f.Type != nil {
+				field.Type = c.resolveType(f.Type)
+			}
+			rec.Fields = append(rec.Fields, field)
+		}
+	}
+}
+
+// checkRecords reports the record fields whose type is still unknown once the
+// whole program is checked, and records that contain themselves. Go cannot
+// lay out a struct that contains itself, except through an array.
+func (c *Checker) checkRecords(records []*parser.RecordStatement) {
+	inCycle := make(map[*RecordType]bool) // cycles are reported once
+	for _, s := range records {
+		typ := c.info.Decls[s]
+		if typ == nil || typ.Record.decl != s {
+			continue
+		}
+		rec := typ.Record
+
+		for _, f := range rec.Fields {
+			if f.Type == nil && !rec.constructed && f.decl.Type == nil {
+				c.errorf(f.decl, "cannot infer the type of field %s of %s, it is never constructed", f.Name, rec.Name).
+					WithHelp("annotate the field: record %s { %s int }", rec.Name, f.Name)
+			}
+		}
+
+		if inCycle[rec] {
+			continue
+		}
+		if path := recordCycle(rec, rec, nil); path != nil {
+			names := make([]string, len(path))
+			for i, step := range path {
+				inCycle[step.record] = true
+				names[i] = step.record.Name + "." + step.field.Name
+			}
+			c.errorf(s, "invalid recursive record %s", rec.Name).
+				WithNote("%s contains itself through %s", rec.Name, strings.Join(names, ", ")).
+				WithHelp("use an array of %s instead, which can be empty", rec.Name)
+		}
+	}
+}
+
+// fieldStep is one field on the path from a record to a record it contains.
+type fieldStep struct {
+	record *RecordType
+	field  *Field
+}
+
+// recordCycle returns the path of fields through which rec contains target
+// directly, or nil if it does not.
+func recordCycle(rec, target *RecordType, seen map[*RecordType]bool) []fieldStep {
+	if seen == nil {
+		seen = make(map[*RecordType]bool)
+	}
+	seen[rec] = true
+	for _, f := range rec.Fields {
+		if f.Type == nil || f.Type.Kind != Record {
+			continue
+		}
+		step := fieldStep{record: rec, field: f}
+		if f.Type.Record == target {
+			return []fieldStep{step}
+		}
+		if seen[f.Type.Record] {
+			continue
+		}
+		if path := recordCycle(f.Type.Record, target, seen); path != nil {
+			return append([]fieldStep{step}, path...)
+		}
+	}
+	return nil
+}
+
+// checkConstruction checks Point(1, 2), which builds a record from a value
+// for every field, in order. Fields without an annotation take the type of
+// their value in the first construction that is checked.
+func (c *Checker) checkConstruction(typ *Type, e *parser.CallExpression) *Type {
+	rec := typ.Record
+	c.info.Records[e] = typ
+
+	if len(e.Arguments) != len(rec.Fields) {
+		c.values(e.Arguments)
+		c.errorf(e, "wrong number of fields for %s: want %d, got %d", rec.Name, len(rec.Fields), len(e.Arguments))
+		return typ
+	}
+
+	for i, arg := range e.Arguments {
+		f := rec.Fields[i]
+		t := c.valueFor(arg, f.Type)
+		switch {
+		case t == nil:
+		case f.Type == nil && !rec.constructed:
+			f.Type = t
+			f.from = arg
+		case f.Type == nil:
+			// The first construction had an error in this field
+		case !c.convert(arg, t, f.Type):
+			d := c.errorf(arg, "cannot use %s (type %s) as %s field %s of %s", arg.String(), t, f.Type, f.Name, rec.Name)
+			if f.from != nil {
+				d.WithNote("the type of %s was inferred from %s at %s", f.Name, f.from.String(), f.from.Pos()).
+					WithHelp("annotate the field: record %s { %s %s }", rec.Name, f.Name, t)
+			}
+		}
+	}
+	rec.constructed = true
+	return typ
+}
+
+// checkField checks p.x and returns the type of the field.
+func (c *Checker) checkField(e *parser.FieldExpression) *Type {
+	t := c.value(e.Record)
+	if t == nil {
+		return nil
+	}
+	if t.Kind != Record {
+		c.errorf(e, "%s (type %s) has no field %s", e.Record.String(), t, e.Field.Value)
+		return nil
+	}
+
+	rec := t.Record
+	f := rec.Field(e.Field.Value)
+	switch {
+	case f == nil:
+		c.errorf(e.Field, "%s has no field %s", rec.Name, e.Field.Value).
+			WithNote("%s is declared at %s", rec.Name, rec.decl.Pos())
+		return nil
+	case f.Type == nil && !rec.constructed:
+		c.errorf(e, "the type of %s is not known yet", e.String()).
+			WithNote("field %s of %s has no type, and no %s was constructed before this", f.Name, rec.Name, rec.Name).
+			WithHelp("annotate the field: record %s { %s int }", rec.Name, f.Name)
+	}
+	return f.Type
+}
@@ -0,0 +1,121 @@
+package checker
+
+import (
+	"go/constant"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+type scopeKind int
+
+const (
+	functionScope scopeKind = iota // main, a function's parameters, or the universe
+	blockScope                     // body of an if, loop or function
+	loopScope                      // variables declared in a for header
+)
+
+type symbolKind int
+
+const (
+	variableSymbol symbolKind = iota
+	parameterSymbol
+	functionSymbol
+	recordSymbol
+)
+
+// symbol is a declared name.
+type symbol struct {
+	name string
+	kind symbolKind
+	pos  lexer.Position
+	end  lexer.Position
+	typ  *Type      // type of a variable or parameter, nil if unknown
+	sig  *Signature // signature of a function
+	used bool
+
+	immutable bool             // declared with const or lazyOnce
+	constant  bool             // declared with const and a value known at compile time
+	lazy      bool             // declared with lazyOnce
+	value     constant.Value   // value of a constant, nil if unknown
+	decl      parser.Statement // const declaration
+
+	captured []*symbol // lazyOnce bindings whose value reads this variable
+	shares   *symbol   // constant whose arrays a for-in variable is an element of
+}
+
+// describe names what an immutable symbol is, for error messages.
+func (s *symbol) describe() string {
+	if s.lazy {
+		return "lazy binding"
+	}
+	return "constant"
+}
+
+// keyword returns the keyword that declared an immutable symbol.
+func (s *symbol) keyword() string {
+	if s.lazy {
+		return "lazyOnce"
+	}
+	return "const"
+}
+
+// scope is one level of the symbol table. The scopes of main and of each
+// function hang directly off the universe scope that holds the functions, so
+// a function never sees the variables of main.
+type scope struct {
+	kind    scopeKind
+	parent  *scope
+	symbols map[string]*symbol
+	ordered []*symbol // symbols in declaration order, for stable diagnostics
+}
+
+func newScope(kind scopeKind, parent *scope) *scope {
+	return &scope{kind: kind, parent: parent, symbols: make(map[string]*symbol)}
+}
+
+func (s *scope) declare(sym *symbol) {
+	s.symbols[sym.name] = sym
+	s.ordered = append(s.ordered, sym)
+}
+
+func (s *scope) lookup(name string) *symbol {
+	for sc := s; sc != nil; sc = sc.parent {
+		if sym, ok := sc.symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// goKeywords are the Go keywords that are not LazyLang keywords. A LazyLang
+// name is used unchanged in the generated Go, so these cannot be names.
+var goKeywords = map[string]bool{
+	"case": true, "chan": true, "default": true, "defer": true, "fallthrough": true,
+	"func": true, "go": true, "goto": true, "import": true, "interface": true,
+	"map": true, "package": true, "range": true, "select": true, "struct": true,
+	"switch": true, "type": true, "var": true,
+}
+
+// goNames are the names the generated Go code refers to: the packages it
+// imports, the runtime helpers and the predeclared Go names it calls. A
+// variable with one of these names would hide them.
+var goNames = map[string]bool{
+	"fmt": true, "math": true, "slices": true, "strings": true, "sync": true,
+	"lazyPowInt": true, "lazyPop": true, "lazyThunk": true,
+	"append": true, "int": true, "float64": true, "bool": true, "string": true,
+}
+
+// checkName reports a declared name that cannot be used in the generated Go.
+// Fields and labels live apart from other names in Go, so for them only the
+// keywords matter.
+func (c *Checker) checkName(node parser.Node, name string, keywordsOnly bool) {
+	switch {
+	case goKeywords[name]:
+		c.errorf(node, "cannot use %s as a name, it is a keyword in Go", name).
+			WithHelp("choose another name")
+	case goNames[name] && !keywordsOnly:
+		c.errorf(node, "cannot use %s as a name, the generated Go code needs it", name).
+			WithHelp("choose another name")
+	}
+}
@@ -0,0 +1,159 @@
+package checker
+
+import (
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+type Kind int
+
+const (
+	Int Kind = iota
+	Float
+	Bool
+	String
+	Array
+	Record
+	Void // result of a function without a return type
+)
+
+// Type is the static type of a LazyLang value.
+type Type struct {
+	Kind   Kind
+	Elem   *Type       // element type of an array
+	Record *RecordType // fields of a record type
+}
+
+// RecordType is a type declared with `record`. Two records are the same type
+// only if they are the same declaration.
+type RecordType struct {
+	Name   string
+	Fields []*Field
+
+	decl        *parser.RecordStatement
+	constructed bool // whether a construction was checked
+}
+
+// Field is a field of a record. Type is nil until it is inferred from the
+// first construction if the declaration has no annotation.
+type Field struct {
+	Name string
+	Type *Type
+
+	decl *parser.RecordField
+	from parser.Expression // value the type was inferred from
+}
+
+// Field returns the field called name, or nil.
+func (r *RecordType) Field(name string) *Field {
+	for _, f := range r.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+var (
+	IntType    = &Type{Kind: Int}
+	FloatType  = &Type{Kind: Float}
+	BoolType   = &Type{Kind: Bool}
+	StringType = &Type{Kind: String}
+	VoidType   = &Type{Kind: Void}
+)
+
+// ArrayOf returns the array type with elements of type elem.
+func ArrayOf(elem *Type) *Type {
+	return &Type{Kind: Array, Elem: elem}
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Bool:
+		return "bool"
+	case String:
+		return "string"
+	case Array:
+		return "[]" + t.Elem.String()
+	case Record:
+		return t.Record.Name
+	default:
+		return "no value"
+	}
+}
+
+// IsNumeric reports whether t is int or float.
+func (t *Type) IsNumeric() bool {
+	return t.Kind == Int || t.Kind == Float
+}
+
+// Identical reports whether a and b are the same type.
+func Identical(a, b *Type) bool {
+	if a.Kind != b.Kind {
+		return false
+	}
+	switch a.Kind {
+	case Array:
+		return Identical(a.Elem, b.Elem)
+	case Record:
+		return a.Record == b.Record
+	}
+	return true
+}
+
+// Comparable reports whether values of type t can be compared with ==, as
+// in Go: arrays cannot, and neither can records with a field that cannot.
+func (t *Type) Comparable() bool {
+	switch t.Kind {
+	case Array:
+		return false
+	case Record:
+		for _, f := range t.Record.Fields {
+			if f.Type != nil && !f.Type.Comparable() {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Signature is the type of a function.
+type Signature struct {
+	Params []*Type
+	Result *Type
+}
+
+// Info holds the types computed by the checker for the code generator.
+type Info struct {
+	// Types maps every well-typed expression to its type.
+	Types map[parser.Expression]*Type
+	// Decls maps `lazy` and `lazyArray` statements to the type of the
+	// variable they bind, and `record` statements to the record type.
+	Decls map[parser.Statement]*Type
+	// Promoted holds the int expressions that are converted to float
+	// because they meet a float in arithmetic, a comparison or an assignment.
+	Promoted map[parser.Expression]bool
+	// Consts holds the const declarations whose value is known at compile
+	// time, which become Go constants.
+	Consts map[parser.Statement]bool
+	// Unused holds the const declarations whose name is never used. Go
+	// allows that for its constants, the others must be marked as used.
+	Unused map[parser.Statement]bool
+	// Lazy holds the identifiers that refer to lazyOnce bindings, which are
+	// read through the thunk that computes their value.
+	Lazy map[parser.Expression]bool
+	// Builtins maps calls of built-in functions, such as the int and float
+	// conversions, to the name of the built-in.
+	Builtins map[*parser.CallExpression]string
+	// Records maps record constructions, such as Point(1, 2), to the record
+	// type they build.
+	Records map[*parser.CallExpression]*Type
+}
+
+// TypeOf returns the type of expr, or nil if it is unknown.
+func (info *Info) TypeOf(expr parser.Expression) *Type {
+	return info.Types[expr]
+}
@@ -2,67 +2,316 @@ package codegen
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/lazydiv/lazyLang-compiler/internal/checker"
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
 	"github.com/lazydiv/lazyLang-compiler/internal/parser"
 )
 
+// Error is reported for AST nodes the generator cannot translate to Go.
+type Error struct {
+	Pos lexer.Position
+	End lexer.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
 type CodeGen struct {
-	variables map[string]bool
+	info    *checker.Info
+	scope   *scope
+	indent  int             // indentation of the statement being generated
+	loops   []*loop         // loops around the statement being generated
+	labels  int             // number of loop labels generated so far
+	taken   map[string]bool // top-level names and loop labels of the program
+	imports map[string]bool // Go packages used by the generated code
+	helpers map[string]bool // runtime helpers used by the generated code
+	records map[string]bool // names of the records of the program
+	errors  []*Error
+}
+
+// loop is a loop whose body is being generated, for break and continue.
+type loop struct {
+	label   string // Go label of the loop, "" if it has none
+	matches int    // match statements entered in the body
 }
 
-func NewCodeGen() *CodeGen {
+// NewCodeGen returns a generator for a program that passed the checker, using
+// the types it computed.
+func NewCodeGen(info *checker.Info) *CodeGen {
 	return &CodeGen{
-		variables: make(map[string]bool),
+		info:    info,
+		imports: make(map[string]bool),
+		helpers: make(map[string]bool),
+		records: make(map[string]bool),
+		taken:   make(map[string]bool),
 	}
 }
+
+// Errors returns the errors found while generating code.
+func (cg *CodeGen) Errors() []*Error {
+	return cg.errors
+}
+
+func (cg *CodeGen) errorAt(node parser.Node, format string, args ...interface{}) {
+	cg.errors = append(cg.errors, &Error{
+		Pos: node.Pos(),
+		End: node.End(),
+		Msg: fmt.Sprintf(format, args...),
+	})
+}
+
+func (cg *CodeGen) openScope(kind scopeKind) {
+	cg.scope = newScope(kind, cg.scope)
+}
+
+func (cg *CodeGen) closeScope() {
+	cg.scope = cg.scope.parent
+}
+
+// use records that the generated code refers to a Go package.
+func (cg *CodeGen) use(pkg string) {
+	cg.imports[pkg] = true
+}
+
 func (cg *CodeGen) Generate(program *parser.Program) string {
-	var out strings.Builder
+	var code strings.Builder
 
-	out.WriteString("package main\n\n")
-	out.WriteString("import \"fmt\"\n\n")
-	out.WriteString("func main() {\n")
+	// Generated names and labels must not clash with the functions,
+	// records and labels of the program
+	for _, stmt := range program.Statements {
+		parser.Inspect(stmt, func(node parser.Node) bool {
+			var label *parser.Identifier
+			switch n := node.(type) {
+			case *parser.FunctionStatement:
+				cg.taken[n.Name] = true
+			case *parser.RecordStatement:
+				cg.taken[n.Name] = true
+			case *parser.ForStatement:
+				label = n.Label
+			case *parser.ForInStatement:
+				label = n.Label
+			case *parser.WhileStatement:
+				label = n.Label
+			}
+			if label != nil {
+				cg.taken[label.Value] = true
+			}
+			return true
+		})
+	}
+
+	// Records become struct types above everything else, so that the
+	// functions can refer to them
+	for _, stmt := range program.Statements {
+		if rec, ok := stmt.(*parser.RecordStatement); ok {
+			cg.records[rec.Name] = true
+			code.WriteString(cg.generateRecord(rec) + "\n\n")
+		}
+	}
 
+	// Functions become top-level Go functions, everything else runs in main
+	var body []parser.Statement
 	for _, stmt := range program.Statements {
-		out.WriteString("\t" + cg.generateStatement(stmt) + "\n")
+		switch s := stmt.(type) {
+		case *parser.RecordStatement:
+		case *parser.FunctionStatement:
+			code.WriteString(cg.generateFunction(s) + "\n\n")
+		default:
+			body = append(body, stmt)
+		}
+	}
+
+	code.WriteString("func main() ")
+	code.WriteString(cg.generateBlock(programScope, body))
+	code.WriteString("\n")
+
+	// The header comes last, once the code has told which packages and
+	// helpers it needs. Go rejects unused imports.
+	var out strings.Builder
+	out.WriteString("package main\n\n")
+	out.WriteString(cg.generateImports())
+	out.WriteString(cg.generateHelpers())
+	out.WriteString(code.String())
+	return out.String()
+}
+
+func (cg *CodeGen) generateImports() string {
+	pkgs := make([]string, 0, len(cg.imports))
+	for pkg := range cg.imports {
+		pkgs = append(pkgs, strconv.Quote(pkg))
+	}
+	sort.Strings(pkgs)
+
+	switch len(pkgs) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("import %s\n\n", pkgs[0])
+	default:
+		return fmt.Sprintf("import (\n\t%s\n)\n\n", strings.Join(pkgs, "\n\t"))
+	}
+}
+
+// generateBlock generates statements in a new scope of the given kind, as a
+// braced Go block one level deeper than the current statement.
+func (cg *CodeGen) generateBlock(kind scopeKind, stmts []parser.Statement) string {
+	return "{\n" + cg.generateStatements(kind, stmts) + strings.Repeat("\t", cg.indent) + "}"
+}
+
+// generateStatements generates statements in a new scope of the given kind,
+// one per line and one level deeper than the current statement.
+func (cg *CodeGen) generateStatements(kind scopeKind, stmts []parser.Statement) string {
+	var out strings.Builder
+
+	cg.openScope(kind)
+	cg.indent++
+	for _, stmt := range stmts {
+		out.WriteString(strings.Repeat("\t", cg.indent) + cg.generateStatement(stmt) + "\n")
 	}
+	cg.indent--
+	cg.closeScope()
+
+	return out.String()
+}
+
+func (cg *CodeGen) generateFunction(fn *parser.FunctionStatement) string {
+	var out strings.Builder
+
+	// Parameters live in the function scope, the body in a block below it
+	cg.openScope(functionScope)
+	defer cg.closeScope()
 
-	out.WriteString("}\n")
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		cg.scope.declare(p.Name)
+		params[i] = fmt.Sprintf("%s %s", p.Name, cg.generateType(p.Type))
+	}
+
+	out.WriteString(fmt.Sprintf("func %s(%s) ", fn.Name, strings.Join(params, ", ")))
+	if fn.ReturnType != nil {
+		out.WriteString(cg.generateType(fn.ReturnType) + " ")
+	}
+	out.WriteString(cg.generateBlock(blockScope, fn.Body))
+	return out.String()
+}
+
+// generateRecord generates the struct type of a record. The checker has
+// inferred the types of the fields without annotation.
+func (cg *CodeGen) generateRecord(rec *parser.RecordStatement) string {
+	fields := cg.info.Decls[rec].Record.Fields
+
+	// Align the types like gofmt does
+	width := 0
+	for _, f := range fields {
+		width = max(width, len(f.Name))
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("type %s struct {\n", rec.Name))
+	for _, f := range fields {
+		out.WriteString(fmt.Sprintf("\t%-*s %s\n", width, f.Name, goType(f.Type)))
+	}
+	out.WriteString("}")
 	return out.String()
 }
 
+// generateType maps a LazyLang type annotation to a Go type.
+func (cg *CodeGen) generateType(t *parser.TypeExpr) string {
+	if t.Elem != nil {
+		return "[]" + cg.generateType(t.Elem)
+	}
+
+	switch t.Name {
+	case "int", "bool", "string":
+		return t.Name
+	case "float":
+		return "float64"
+	default:
+		if !cg.records[t.Name] {
+			cg.errorAt(t, "unknown type %s", t.Name)
+		}
+		return t.Name
+	}
+}
+
+// goType returns the Go spelling of a LazyLang type.
+func goType(t *checker.Type) string {
+	switch t.Kind {
+	case checker.Float:
+		return "float64"
+	case checker.Array:
+		return "[]" + goType(t.Elem)
+	default:
+		return t.String()
+	}
+}
+
+// bind returns the Go operator that binds name in the current scope: `=` if
+// the name is already visible, otherwise `:=` after declaring it.
+func (cg *CodeGen) bind(name string) string {
+	if cg.scope.lookup(name) != nil {
+		return "="
+	}
+	cg.scope.declare(name)
+	return ":="
+}
+
 // compiler transalotr from ast to go CodeGen
 // compiler to asm
 func (cg *CodeGen) generateStatement(stmt parser.Statement) string {
 	switch s := stmt.(type) {
 	case *parser.VarStatement:
-		varName := s.Name
-		varExpr := cg.generateExpression(s.Value)
+		value := cg.generateExpression(s.Value)
+		op := cg.bind(s.Name)
+
+		// An annotated declaration needs the type spelled out, the value
+		// alone may have a different default type in Go
+		if op == ":=" && s.Type != nil {
+			return fmt.Sprintf("var %s %s = %s", s.Name, goType(cg.info.Decls[s]), value)
+		}
+		return fmt.Sprintf("%s %s %s", s.Name, op, value)
+	case *parser.ConstStatement:
+		value := cg.generateExpression(s.Value)
+		cg.scope.declare(s.Name)
 
-		if cg.variables[varName] {
-			return fmt.Sprintf("%s = %s", varName, varExpr)
-		} else {
-			cg.variables[varName] = true
-			return fmt.Sprintf("%s := %s", varName, varExpr)
+		if cg.info.Consts[s] {
+			return fmt.Sprintf("const %s %s = %s", s.Name, goType(cg.info.Decls[s]), value)
+		}
+		decl := fmt.Sprintf("%s := %s", s.Name, value)
+		if s.Type != nil {
+			decl = fmt.Sprintf("var %s %s = %s", s.Name, goType(cg.info.Decls[s]), value)
+		}
+		if cg.info.Unused[s] {
+			decl += "\n" + strings.Repeat("\t", cg.indent) + "_ = " + s.Name
 		}
+		return decl
+
+	case *parser.LazyStatement:
+		// The value moves into a function that runs on first use, and
+		// every use of the name reads it through get
+		value := cg.generateExpression(s.Value)
+		cg.scope.declare(s.Name)
+		typ := goType(cg.info.Decls[s])
+		return fmt.Sprintf("%s := &%s[%s]{f: func() %s { return %s }}", s.Name, cg.helper("lazyThunk"), typ, typ, value)
+
 	case *parser.ForStatement:
 		var out strings.Builder
+
+		// Variables declared in the header are only visible in the loop
+		cg.openScope(loopScope)
+		defer cg.closeScope()
+
 		out.WriteString("for ")
 
 		// Handle initialization
 		if s.Init != nil {
-			initStmt := cg.generateStatement(s.Init)
-			if varStmt, ok := s.Init.(*parser.VarStatement); ok {
-				varName := varStmt.Name
-				if cg.variables[varName] {
-					// Variable already declared, use "=" instead of ":="
-					if parts := strings.Split(initStmt, ":="); len(parts) == 2 {
-						initStmt = parts[0] + "= " + parts[1]
-					}
-				}
-			}
-			out.WriteString(initStmt)
+			out.WriteString(cg.generateStatement(s.Init))
 		}
 		out.WriteString("; ")
 
@@ -74,50 +323,46 @@ func (cg *CodeGen) generateStatement(stmt parser.Statement) string {
 
 		// Handle post statement
 		if s.Post != nil {
-			postStmt := cg.generateStatement(s.Post)
-			if varStmt, ok := s.Post.(*parser.VarStatement); ok {
-				varName := varStmt.Name
-				if cg.variables[varName] {
-					// Variable already declared, use "=" instead of ":="
-					if parts := strings.Split(postStmt, ":="); len(parts) == 2 {
-						postStmt = parts[0] + "= " + parts[1]
-					}
-				}
-			}
-			out.WriteString(postStmt)
+			out.WriteString(cg.generateStatement(s.Post))
 		}
 
-		out.WriteString(" {\n")
+		return cg.generateLoop(s.Label, out.String(), s.Body)
 
-		for _, stmt := range s.Body {
-			out.WriteString("\t" + cg.generateStatement(stmt) + "\n")
-		}
+	case *parser.ForInStatement:
+		return cg.generateForIn(s)
 
-		out.WriteString("}")
+	case *parser.WhileStatement:
+		condition := cg.generateExpression(s.Condition)
+		return cg.generateLoop(s.Label, "for "+condition, s.Body)
 
-		return out.String()
+	case *parser.BranchStatement:
+		if s.Label != nil {
+			return s.Keyword + " " + s.Label.Value
+		}
+
+		// In Go, break inside a switch leaves the switch, so leaving the
+		// loop from a match needs a label
+		l := cg.loops[len(cg.loops)-1]
+		if s.Keyword == "break" && l.matches > 0 {
+			for l.label == "" {
+				cg.labels++
+				if name := fmt.Sprintf("lazyLoop%d", cg.labels); !cg.taken[name] {
+					l.label = name
+				}
+			}
+			return "break " + l.label
+		}
+		return s.Keyword
 
 	case *parser.ArrayStatement:
-		arrName := s.Name
 		var out strings.Builder
 
-		if cg.variables[arrName] {
-			out.WriteString(fmt.Sprintf("%s = []interface{}{", arrName))
-		} else {
-			cg.variables[arrName] = true
-			out.WriteString(fmt.Sprintf("%s := []interface{}{", arrName))
-		}
-
+		values := make([]string, len(s.Values))
 		for i, v := range s.Values {
-			if i != 0 {
-				out.WriteString(", ")
-			}
-			if v != nil {
-				out.WriteString(cg.generateExpression(v))
-			} else {
-				out.WriteString("nil")
-			}
+			values[i] = cg.generateExpression(v)
 		}
+		out.WriteString(fmt.Sprintf("%s %s %s{", s.Name, cg.bind(s.Name), goType(cg.info.Decls[s])))
+		out.WriteString(strings.Join(values, ", "))
 		out.WriteString("}")
 
 		return out.String()
@@ -125,44 +370,273 @@ func (cg *CodeGen) generateStatement(stmt parser.Statement) string {
 		var out strings.Builder
 		condition := cg.generateExpression(s.Condition)
 
-		out.WriteString(fmt.Sprintf("if %s {\n", condition))
-		for _, stmt := range s.Consequence {
-			out.WriteString("\t\t" + cg.generateStatement(stmt) + "\n")
-		}
+		out.WriteString(fmt.Sprintf("if %s ", condition))
+		out.WriteString(cg.generateBlock(blockScope, s.Consequence))
 
+		// An alternative that is just another if continues the chain
+		if len(s.Alternative) == 1 {
+			if elseIf, ok := s.Alternative[0].(*parser.IfStatement); ok {
+				out.WriteString(" else " + cg.generateStatement(elseIf))
+				return out.String()
+			}
+		}
 		if len(s.Alternative) > 0 {
-			out.WriteString("\t} else {\n")
-			for _, stmt := range s.Alternative {
-				out.WriteString("\t\t" + cg.generateStatement(stmt) + "\n")
+			out.WriteString(" else ")
+			out.WriteString(cg.generateBlock(blockScope, s.Alternative))
+		}
+
+		return out.String()
+
+	case *parser.MatchStatement:
+		var out strings.Builder
+
+		if n := len(cg.loops); n > 0 {
+			cg.loops[n-1].matches++
+			defer func() { cg.loops[n-1].matches-- }()
+		}
+
+		// Go cases do not fall through, so a switch runs one arm like match
+		out.WriteString(fmt.Sprintf("switch %s {\n", cg.generateExpression(s.Value)))
+		for _, arm := range s.Arms {
+			out.WriteString(strings.Repeat("\t", cg.indent))
+			if len(arm.Patterns) == 0 {
+				out.WriteString("default:\n")
+			} else {
+				patterns := make([]string, len(arm.Patterns))
+				for i, p := range arm.Patterns {
+					patterns[i] = cg.generateExpression(p)
+				}
+				out.WriteString(fmt.Sprintf("case %s:\n", strings.Join(patterns, ", ")))
 			}
+			out.WriteString(cg.generateStatements(blockScope, arm.Body))
 		}
+		out.WriteString(strings.Repeat("\t", cg.indent) + "}")
 
-		out.WriteString("\t}")
 		return out.String()
 
+	case *parser.ReturnStatement:
+		if s.Value == nil {
+			return "return"
+		}
+		return "return " + cg.generateExpression(s.Value)
+
+	case *parser.AssignStatement:
+		return fmt.Sprintf("%s = %s", cg.generateExpression(s.Target), cg.generateExpression(s.Value))
+
+	case *parser.CompoundAssignStatement:
+		return fmt.Sprintf("%s %s %s", cg.generateExpression(s.Target), s.Operator, cg.generateExpression(s.Value))
+
+	case *parser.IncDecStatement:
+		return cg.generateExpression(s.Target) + s.Operator
+
+	case *parser.ExpressionStatement:
+		return cg.generateExpression(s.Expression)
+
 	case *parser.PrintStatement:
-		expr := cg.generateExpression(s.Value)
-		return fmt.Sprintf("fmt.Println(%s)", expr)
+		values := make([]string, len(s.Values))
+		for i, v := range s.Values {
+			values[i] = cg.generateExpression(v)
+		}
+		cg.use("fmt")
+		return fmt.Sprintf("fmt.Println(%s)", strings.Join(values, ", "))
 	default:
+		cg.errorAt(stmt, "cannot generate code for %T", stmt)
 		return ""
 	}
 }
 
+// generateForIn generates a for-in loop: a range loop over an array, or a
+// counted loop over a range of ints. The end of a range is evaluated once,
+// before the first iteration.
+func (cg *CodeGen) generateForIn(s *parser.ForInStatement) string {
+	cg.openScope(loopScope)
+	defer cg.closeScope()
+
+	rng, ok := s.Iterable.(*parser.RangeExpression)
+	if !ok {
+		iterable := cg.generateExpression(s.Iterable)
+		if s.Value.Value == "_" && (s.Index == nil || s.Index.Value == "_") {
+			return cg.generateLoop(s.Label, "for range "+iterable, s.Body)
+		}
+		vars := "_, " + s.Value.Value
+		switch {
+		case s.Index != nil && s.Value.Value == "_":
+			vars = s.Index.Value
+		case s.Index != nil:
+			vars = s.Index.Value + ", " + s.Value.Value
+		}
+		for _, name := range strings.Split(vars, ", ") {
+			cg.scope.declare(name)
+		}
+		return cg.generateLoop(s.Label, fmt.Sprintf("for %s := range %s", vars, iterable), s.Body)
+	}
+
+	from := cg.generateExpression(rng.From)
+	to := cg.generateExpression(rng.To)
+	counter := s.Value.Value
+	if counter == "_" {
+		counter = cg.fresh("lazyI")
+	}
+	cg.scope.declare(counter)
+
+	cmp := "<"
+	if rng.Inclusive {
+		cmp = "<="
+	}
+
+	var header string
+	if _, ok := rng.To.(*parser.IntegerLiteral); ok {
+		header = fmt.Sprintf("%s := %s; %s %s %s; %s++", counter, from, counter, cmp, to, counter)
+	} else {
+		end := cg.fresh("lazyEnd")
+		header = fmt.Sprintf("%s, %s := %s, %s; %s %s %s; %s++", counter, end, from, to, counter, cmp, end, counter)
+	}
+	return cg.generateLoop(s.Label, "for "+header, s.Body)
+}
+
+// fresh returns name, or name with a number added, such that it does not
+// hide a variable, function or record that the code being generated can see.
+func (cg *CodeGen) fresh(name string) string {
+	candidate := name
+	for i := 2; cg.scope.lookup(candidate) != nil || cg.taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s%d", name, i)
+	}
+	return candidate
+}
+
+// generateLoop generates a loop from its header and body. The Go loop gets
+// the label of the LazyLang loop, or a generated one if a break in a match
+// needs it.
+func (cg *CodeGen) generateLoop(label *parser.Identifier, header string, body []parser.Statement) string {
+	l := &loop{}
+	if label != nil {
+		l.label = label.Value
+	}
+
+	cg.loops = append(cg.loops, l)
+	code := header + " " + cg.generateBlock(blockScope, body)
+	cg.loops = cg.loops[:len(cg.loops)-1]
+
+	if l.label != "" {
+		code = l.label + ":\n" + strings.Repeat("\t", cg.indent) + code
+	}
+	return code
+}
+
+// generateExpression generates expr, converting it to float64 where the
+// checker promoted an int to float.
 func (cg *CodeGen) generateExpression(expr parser.Expression) string {
+	if !cg.info.Promoted[expr] {
+		return cg.generateOperand(expr)
+	}
+	return fmt.Sprintf("float64(%s)", cg.generateOperand(expr))
+}
+
+func (cg *CodeGen) generateOperand(expr parser.Expression) string {
 	switch e := expr.(type) {
 	case *parser.Identifier:
+		if cg.info.Lazy[e] {
+			return e.Value + ".get()"
+		}
 		return e.Value
-	case *parser.NumberLiteral:
-		return strconv.FormatFloat(e.Value, 'f', -1, 64)
+	case *parser.IntegerLiteral:
+		return strconv.FormatInt(e.Value, 10)
+	case *parser.FloatLiteral:
+		// Go computes with untyped float constants exactly, so 0.1 + 0.2
+		// would equal 0.3 only when both are literals
+		return fmt.Sprintf("float64(%s)", parser.FormatFloat(e.Value))
+	case *parser.StringLiteral:
+		return strconv.Quote(e.Value)
+	case *parser.BooleanLiteral:
+		return strconv.FormatBool(e.Value)
+	case *parser.PrefixExpression:
+		return fmt.Sprintf("(%s%s)", e.Operator, cg.generateExpression(e.Right))
 	case *parser.InfixExpression:
 		left := cg.generateExpression(e.Left)
 		right := cg.generateExpression(e.Right)
+		if e.Operator == "**" {
+			return cg.generatePower(e, left, right)
+		}
 		return fmt.Sprintf("(%s %s %s)", left, e.Operator, right)
+	case *parser.CallExpression:
+		args := make([]string, len(e.Arguments))
+		for i, a := range e.Arguments {
+			args[i] = cg.generateExpression(a)
+		}
+		if builtin, ok := cg.info.Builtins[e]; ok {
+			return cg.generateBuiltin(builtin, e, args)
+		}
+		if typ, ok := cg.info.Records[e]; ok {
+			// A construction lists the values in field order
+			fields := make([]string, len(args))
+			for i, f := range typ.Record.Fields {
+				fields[i] = f.Name + ": " + args[i]
+			}
+			return fmt.Sprintf("%s{%s}", typ.Record.Name, strings.Join(fields, ", "))
+		}
+		return fmt.Sprintf("%s(%s)", cg.generateExpression(e.Function), strings.Join(args, ", "))
+	case *parser.ArrayLiteral:
+		elements := make([]string, len(e.Elements))
+		for i, el := range e.Elements {
+			elements[i] = cg.generateExpression(el)
+		}
+		return fmt.Sprintf("%s{%s}", goType(cg.info.TypeOf(e)), strings.Join(elements, ", "))
 	case *parser.IndexExpression:
 		array := cg.generateExpression(e.Array)
 		index := cg.generateExpression(e.Index)
 		return fmt.Sprintf("%s[%s]", array, index)
+	case *parser.FieldExpression:
+		record := cg.generateExpression(e.Record)
+		if call, ok := e.Record.(*parser.CallExpression); ok && cg.info.Records[call] != nil {
+			// Go would read the braces of Point{...}.x in the header of an
+			// if or a loop as the start of the body
+			record = "(" + record + ")"
+		}
+		return record + "." + e.Field.Value
 	default:
+		cg.errorAt(expr, "cannot generate code for %T", expr)
 		return ""
 	}
 }
+
+// generatePower generates a ** b, which Go only has for floats.
+func (cg *CodeGen) generatePower(e *parser.InfixExpression, left, right string) string {
+	if t := cg.info.TypeOf(e); t != nil && t.Kind == checker.Int {
+		return cg.helper("lazyPowInt") + fmt.Sprintf("(%s, %s)", left, right)
+	}
+	cg.use("math")
+	return fmt.Sprintf("math.Pow(%s, %s)", left, right)
+}
+
+// generateBuiltin generates a call of a built-in function with the already
+// generated arguments. Arrays that append and slice return are clipped to
+// their length, so that appending to them later copies them instead of
+// writing into the array they came from.
+func (cg *CodeGen) generateBuiltin(name string, e *parser.CallExpression, args []string) string {
+	switch name {
+	case "float":
+		return fmt.Sprintf("float64(%s)", args[0])
+	case "append":
+		cg.use("slices")
+		return fmt.Sprintf("append(slices.Clip(%s), %s)", args[0], strings.Join(args[1:], ", "))
+	case "push":
+		return fmt.Sprintf("%s = append(%s, %s)", args[0], args[0], strings.Join(args[1:], ", "))
+	case "pop":
+		return fmt.Sprintf("%s(&%s)", cg.helper("lazyPop"), args[0])
+	case "slice":
+		if t := cg.info.TypeOf(e.Arguments[0]); t != nil && t.Kind == checker.String {
+			return fmt.Sprintf("%s[%s:%s]", args[0], args[1], args[2])
+		}
+		cg.use("slices")
+		return fmt.Sprintf("slices.Clone(%s[%s:%s])", args[0], args[1], args[2])
+	case "contains":
+		if t := cg.info.TypeOf(e.Arguments[0]); t != nil && t.Kind == checker.String {
+			cg.use("strings")
+			return fmt.Sprintf("strings.Contains(%s, %s)", args[0], args[1])
+		}
+		cg.use("slices")
+		return fmt.Sprintf("slices.Contains(%s, %s)", args[0], args[1])
+	default:
+		return fmt.Sprintf("%s(%s)", name, strings.Join(args, ", "))
+	}
+}
@@ -0,0 +1,214 @@
+package codegen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/checker"
+	"github.com/lazydiv/lazyLang-compiler/internal/diagnostics"
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+	"github.com/lazydiv/lazyLang-compiler/internal/parser"
+)
+
+// generate compiles input to Go, failing the test on any error.
+func generate(t *testing.T, input string) string {
+	t.Helper()
+	l := lexer.NewLexer(input)
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if len(l.Errors()) > 0 || len(p.Errors()) > 0 {
+		t.Fatalf("cannot parse %q", input)
+	}
+
+	c := checker.NewChecker()
+	for _, d := range c.Check(program) {
+		if d.Severity == diagnostics.Error {
+			t.Fatalf("check error: %s", d.Message)
+		}
+	}
+
+	cg := NewCodeGen(c.Info())
+	code := cg.Generate(program)
+	for _, err := range cg.Errors() {
+		t.Errorf("codegen error: %s", err.Msg)
+	}
+	return code
+}
+
+// checkContains checks that code has the given lines, in order and with the
+// given indentation.
+func checkContains(t *testing.T, code string, lines ...string) {
+	t.Helper()
+	want := strings.Join(lines, "\n")
+	if !strings.Contains(code, want) {
+		t.Errorf("generated code does not contain\n%s\n\ngot:\n%s", want, code)
+	}
+}
+
+// genTest is a program and lines that its generated Go must contain, in
+// order and with the given indentation.
+type genTest struct {
+	name  string
+	input string
+	want  []string
+}
+
+func runGenTests(t *testing.T, tests []genTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkContains(t, generate(t, tt.input), tt.want...)
+		})
+	}
+}
+
+func TestWhile(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"inside if", "lazy n = 3\nif n > 0 {\n  lazy m = 0\n  while m < n {\n    lazyPrint(m)\n    m++\n  }\n}", []string{
+			"\tif (n > 0) {",
+			"\t\tm := 0",
+			"\t\tfor (m < n) {",
+			"\t\t\tfmt.Println(m)",
+			"\t\t\tm++",
+			"\t\t}",
+			"\t}",
+		}},
+		{"inside for", "for (i = 0; i < 2; i++) {\n  lazy j = 0\n  while j < 2 {\n    lazyPrint(i, j)\n    j++\n  }\n}", []string{
+			"\tfor i := 0; (i < 2); i++ {",
+			"\t\tj := 0",
+			"\t\tfor (j < 2) {",
+			"\t\t\tfmt.Println(i, j)",
+			"\t\t\tj++",
+			"\t\t}",
+			"\t}",
+		}},
+	})
+}
+
+func TestMatch(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"cases", "lazy x = 2\nmatch x {\n1, 2 => lazyPrint(\"small\")\n_ => lazyPrint(\"other\")\n}", []string{
+			"\tswitch x {",
+			"\tcase 1, 2:",
+			"\t\tfmt.Println(\"small\")",
+			"\tdefault:",
+			"\t\tfmt.Println(\"other\")",
+			"\t}",
+		}},
+		{"break leaves the loop", "for i in 0..3 {\nmatch i {\n1 => break\n_ => lazyPrint(i)\n}\n}", []string{
+			"\tlazyLoop1:",
+			"\tfor i := 0; i < 3; i++ {",
+			"\t\tswitch i {",
+			"\t\tcase 1:",
+			"\t\t\tbreak lazyLoop1",
+		}},
+	})
+}
+
+func TestBuiltins(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"slice copies", "lazy a = [1, 2, 3]\nlazy s = slice(a, 0, 2)\nlazyPrint(s)", []string{
+			"\ts := slices.Clone(a[0:2])",
+		}},
+		{"slice string", "lazy s = slice(\"hello\", 1, 3)\nlazyPrint(s)", []string{
+			"\ts := \"hello\"[1:3]",
+		}},
+		{"append copies", "lazy a = [1]\nlazy b = append(a, 2, 3)\nlazyPrint(b)", []string{
+			"\tb := append(slices.Clip(a), 2, 3)",
+		}},
+		{"push and pop", "lazy a = [1]\npush(a, 2)\nlazy last = pop(a)\nlazyPrint(last)", []string{
+			"\ta = append(a, 2)",
+			"\tlast := lazyPop(&a)",
+		}},
+		{"push converts", "lazy a = [1.5]\npush(a, 2)\nlazyPrint(a)", []string{
+			"\ta = append(a, float64(2))",
+		}},
+	})
+}
+
+func TestGeneratedNames(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"blank counter", "for _ in 0..2 {\nlazyPrint(1)\n}", []string{
+			"\tfor lazyI := 0; lazyI < 2; lazyI++ {",
+		}},
+		{"counter and record", "record lazyI { v int }\nfor _ in 0..2 {\nlazyPrint(lazyI(1))\n}", []string{
+			"\tfor lazyI2 := 0; lazyI2 < 2; lazyI2++ {",
+		}},
+		{"end and variable", "lazy lazyEnd = 3\nfor i in 0..lazyEnd {\nlazyPrint(i, lazyEnd)\n}", []string{
+			"\tfor i, lazyEnd2 := 0, lazyEnd; i < lazyEnd2; i++ {",
+		}},
+		{"end and function", "lazyFn lazyEnd() int {\nreturn 3\n}\nfor i in 0..lazyEnd() {\nlazyPrint(i)\n}", []string{
+			"\tfor i, lazyEnd2 := 0, lazyEnd(); i < lazyEnd2; i++ {",
+		}},
+		{"label and user label", "lazyLoop1: for i in 0..3 {\nbreak lazyLoop1\n}\nfor j in 0..3 {\nmatch j {\n1 => break\n_ => lazyPrint(j)\n}\n}", []string{
+			"\tlazyLoop2:",
+			"\tfor j := 0; j < 3; j++ {",
+		}},
+		{"label and function", "lazyFn lazyLoop1() {\n}\nfor j in 0..3 {\nmatch j {\n1 => break\n_ => lazyLoop1()\n}\n}", []string{
+			"\tlazyLoop2:",
+			"\tfor j := 0; j < 3; j++ {",
+		}},
+	})
+}
+
+func TestNumbers(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"float literals are float64", "lazyPrint(0.1 + 0.2 == 0.3)", []string{
+			"\tfmt.Println(((float64(0.1) + float64(0.2)) == float64(0.3)))",
+		}},
+		{"whole float", "lazy x = 1.0\nlazyPrint(x)", []string{
+			"\tx := float64(1.0)",
+		}},
+		{"promoted int", "lazy x = 1.5\nlazyPrint(x + 2)", []string{
+			"\tfmt.Println((x + float64(2)))",
+		}},
+		{"integer division", "lazy x = 7\nlazyPrint(x / 2)", []string{
+			"\tfmt.Println((x / 2))",
+		}},
+		{"float constant", "const half = 0.5\nlazyPrint(half)", []string{
+			"\tconst half float64 = float64(0.5)",
+		}},
+	})
+}
+
+func TestLazyOnce(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"thunk", "lazy n = 2\nlazyOnce sq = n * n\nlazyPrint(sq, sq)", []string{
+			"\tsq := &lazyThunk[int]{f: func() int { return (n * n) }}",
+			"\tfmt.Println(sq.get(), sq.get())",
+		}},
+		{"helper", "lazyOnce s = \"a\"\nlazyPrint(s)", []string{
+			"type lazyThunk[T any] struct {",
+		}},
+	})
+}
+
+func TestRecords(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"struct", "record Point { x, label string }\nlazyPrint(Point(1, \"a\"))", []string{
+			"type Point struct {",
+			"\tx     int",
+			"\tlabel string",
+			"}",
+		}},
+		{"construction", "record Point { x, y }\nlazy p = Point(1, 2)\np.x = 3\nlazyPrint(p)", []string{
+			"\tp := Point{x: 1, y: 2}",
+			"\tp.x = 3",
+		}},
+		{"field of construction in condition", "record Point { x int }\nif Point(1).x > 0 {\nlazyPrint(1)\n}", []string{
+			"\tif ((Point{x: 1}).x > 0) {",
+		}},
+	})
+}
+
+func TestConst(t *testing.T) {
+	runGenTests(t, []genTest{
+		{"go constant", "const limit = 10\nlazyPrint(limit)", []string{
+			"\tconst limit int = 10",
+		}},
+		{"unused array", "const xs = [1, 2]", []string{
+			"\txs := []int{1, 2}",
+			"\t_ = xs",
+		}},
+	})
+}
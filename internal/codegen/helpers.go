@@ -0,0 +1,93 @@
+package codegen
+
+import (
+	"sort"
+	"strings"
+)
+
+// helperSource holds the Go source of the runtime helpers that generated code
+// calls for operations Go has no operator for. Only the helpers a program
+// uses are emitted.
+var helperSource = map[string]string{
+	// Integer power by squaring. A negative exponent truncates towards zero,
+	// like integer division: only 1 and -1 have a non-zero result.
+	"lazyPowInt": `func lazyPowInt(base, exp int) int {
+	if exp < 0 {
+		switch base {
+		case 1:
+			return 1
+		case -1:
+			if exp%2 == 0 {
+				return 1
+			}
+			return -1
+		default:
+			return 0
+		}
+	}
+	result := 1
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}`,
+
+	// Removes and returns the last element, like pop in most languages.
+	"lazyPop": `func lazyPop[T any](a *[]T) T {
+	if len(*a) == 0 {
+		panic("pop from empty array")
+	}
+	last := (*a)[len(*a)-1]
+	*a = (*a)[:len(*a)-1]
+	return last
+}`,
+
+	// The value of a lazyOnce binding: f runs the first time get is called,
+	// and later calls return the value it computed.
+	"lazyThunk": `type lazyThunk[T any] struct {
+	once  sync.Once
+	f     func() T
+	value T
+}
+
+func (t *lazyThunk[T]) get() T {
+	t.once.Do(func() {
+		t.value = t.f()
+		t.f = nil
+	})
+	return t.value
+}`,
+}
+
+// helperImports holds the Go packages that helpers refer to.
+var helperImports = map[string]string{
+	"lazyThunk": "sync",
+}
+
+// helper records that the generated code calls the named helper and returns
+// the name.
+func (cg *CodeGen) helper(name string) string {
+	cg.helpers[name] = true
+	if pkg, ok := helperImports[name]; ok {
+		cg.use(pkg)
+	}
+	return name
+}
+
+func (cg *CodeGen) generateHelpers() string {
+	names := make([]string, 0, len(cg.helpers))
+	for name := range cg.helpers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out strings.Builder
+	for _, name := range names {
+		out.WriteString(helperSource[name] + "\n\n")
+	}
+	return out.String()
+}
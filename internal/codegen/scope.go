@@ -0,0 +1,43 @@
+package codegen
+
+type scopeKind int
+
+const (
+	programScope  scopeKind = iota // statements of main
+	functionScope                  // parameters of a function
+	blockScope                     // body of an if, loop or function
+	loopScope                      // variables declared in a for header
+)
+
+// scope is one level of the symbol table. Go scoping rules are lexical, so
+// the generator keeps a scope per LazyLang block and mirrors it in the Go
+// output: a name is declared with `:=` the first time it is bound in the
+// scopes visible from the current function, and assigned with `=` after that.
+type scope struct {
+	kind   scopeKind
+	parent *scope
+	names  map[string]bool
+}
+
+func newScope(kind scopeKind, parent *scope) *scope {
+	return &scope{kind: kind, parent: parent, names: make(map[string]bool)}
+}
+
+func (s *scope) declare(name string) {
+	s.names[name] = true
+}
+
+// lookup returns the scope that declares name. The search stops at the
+// enclosing function, since generated Go functions cannot see the variables
+// of main; a declaration with the same name inside a function shadows them.
+func (s *scope) lookup(name string) *scope {
+	for sc := s; sc != nil; sc = sc.parent {
+		if sc.names[name] {
+			return sc
+		}
+		if sc.kind == functionScope {
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// Diagnostic is a message about a range of the source file, with optional
+// notes and a help line that suggests a fix.
+type Diagnostic struct {
+	Severity Severity
+	Pos      lexer.Position
+	End      lexer.Position
+	Message  string
+	Notes    []string
+	Help     string
+}
+
+// New returns a diagnostic covering the range from pos to end.
+func New(severity Severity, pos, end lexer.Position, message string) *Diagnostic {
+	return &Diagnostic{Severity: severity, Pos: pos, End: end, Message: message}
+}
+
+// Errorf returns an error diagnostic with a formatted message.
+func Errorf(pos, end lexer.Position, format string, args ...interface{}) *Diagnostic {
+	return New(Error, pos, end, fmt.Sprintf(format, args...))
+}
+
+// Warningf returns a warning diagnostic with a formatted message.
+func Warningf(pos, end lexer.Position, format string, args ...interface{}) *Diagnostic {
+	return New(Warning, pos, end, fmt.Sprintf(format, args...))
+}
+
+// WithNote appends a note and returns d, so it can be chained.
+func (d *Diagnostic) WithNote(format string, args ...interface{}) *Diagnostic {
+	d.Notes = append(d.Notes, fmt.Sprintf(format, args...))
+	return d
+}
+
+// WithHelp sets the help line and returns d, so it can be chained.
+func (d *Diagnostic) WithHelp(format string, args ...interface{}) *Diagnostic {
+	d.Help = fmt.Sprintf(format, args...)
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	if !d.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diags []*Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders diagnostics by their position in the source.
+func Sort(diags []*Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Pos.Offset < diags[j].Pos.Offset
+	})
+}
+
+// Printer renders diagnostics together with the source line they point to:
+//
+//	example.lazy:3:8: error: expected `=` after identifier `x`, got `[`
+//	   |
+//	 3 | lazy x [ 3
+//	   |        ^
+//	   = help: declare variables with `lazy name = value`
+type Printer struct {
+	w     io.Writer
+	lines []string
+}
+
+// NewPrinter returns a printer that writes to w and quotes lines of source.
+func NewPrinter(w io.Writer, source string) *Printer {
+	return &Printer{w: w, lines: strings.Split(source, "\n")}
+}
+
+// Print writes a single diagnostic.
+func (p *Printer) Print(d *Diagnostic) {
+	fmt.Fprintln(p.w, d.Error())
+
+	if d.Pos.IsValid() && d.Pos.Line <= len(p.lines) {
+		line := strings.TrimRight(p.lines[d.Pos.Line-1], "\r")
+		number := fmt.Sprint(d.Pos.Line)
+		gutter := strings.Repeat(" ", len(number)+1)
+
+		fmt.Fprintf(p.w, "%s|\n", gutter)
+		fmt.Fprintf(p.w, " %s | %s\n", number, line)
+		fmt.Fprintf(p.w, "%s| %s\n", gutter, underline(line, d.Pos, d.End))
+		for _, note := range d.Notes {
+			fmt.Fprintf(p.w, "%s= note: %s\n", gutter, note)
+		}
+		if d.Help != "" {
+			fmt.Fprintf(p.w, "%s= help: %s\n", gutter, d.Help)
+		}
+	} else {
+		for _, note := range d.Notes {
+			fmt.Fprintf(p.w, "  = note: %s\n", note)
+		}
+		if d.Help != "" {
+			fmt.Fprintf(p.w, "  = help: %s\n", d.Help)
+		}
+	}
+}
+
+// PrintAll writes diagnostics in source order followed by a summary line.
+// When several errors point at the same position only the first is shown,
+// since the lexer and parser often both complain about a malformed token.
+func (p *Printer) PrintAll(diags []*Diagnostic) {
+	Sort(diags)
+	errors, warnings := 0, 0
+	for i, d := range diags {
+		if i > 0 && d.Severity == Error && diags[i-1].Severity == Error && diags[i-1].Pos == d.Pos {
+			continue
+		}
+		p.Print(d)
+		fmt.Fprintln(p.w)
+		if d.Severity == Error {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+
+	var summary []string
+	if errors > 0 {
+		summary = append(summary, plural(errors, "error"))
+	}
+	if warnings > 0 {
+		summary = append(summary, plural(warnings, "warning"))
+	}
+	if len(summary) > 0 {
+		fmt.Fprintln(p.w, strings.Join(summary, ", "))
+	}
+}
+
+// underline returns the caret line for the range pos..end of line. Tabs
+// before the range are kept so the carets line up with the source.
+func underline(line string, pos, end lexer.Position) string {
+	runes := []rune(line)
+	start := pos.Column - 1
+	if start > len(runes) {
+		start = len(runes)
+	}
+	stop := start + 1
+	if end.Line == pos.Line && end.Column-1 > start {
+		stop = end.Column - 1
+	} else if end.Line > pos.Line {
+		stop = len(runes)
+	}
+	if stop <= start {
+		stop = start + 1
+	}
+
+	var out strings.Builder
+	for _, r := range runes[:start] {
+		if r == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+	}
+	out.WriteString(strings.Repeat("^", stop-start))
+	return out.String()
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
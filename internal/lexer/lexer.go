@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"fmt"
 	"strings"
 	"text/scanner"
 )
@@ -11,24 +12,57 @@ type TokenType int
 const (
 	ILLEGAL TokenType = iota
 	EOF
+	COMMENT
 	IDENT
-	NUMBER
+	INT
+	FLOAT
+	STRING
 	VAR
+	CONST
+	ONCE
 	IF
 	ELSE
 	FOR
 	ARRAY
 	WHILE
 	PRINT
+	FUNCTION
+	RETURN
+	RECORD
+	MATCH
+	BREAK
+	CONTINUE
+	TRUE
+	FALSE
 
 	// Operators
 	PLUS
 	MINUS
 	MULTIPLY
 	DIVIDE
+	MODULO
+	POWER
 	ASSIGN
+	ARROW // => in a match arm
+	PLUS_ASSIGN
+	MINUS_ASSIGN
+	MULTIPLY_ASSIGN
+	DIVIDE_ASSIGN
+	MODULO_ASSIGN
 	DECREMENT
 	INCREMENT
+	BANG
+
+	// Logical operators
+	AND
+	OR
+
+	// Bitwise operators
+	BIT_AND
+	BIT_OR
+	BIT_XOR
+	SHL
+	SHR
 
 	// Delimiters
 	LPAREN
@@ -38,7 +72,11 @@ const (
 	LSBREC
 	RSBREC
 	SEMICOLON
+	COLON
 	COMMA
+	DOT       // .
+	DOTDOT    // ..
+	DOTDOT_EQ // ..=
 
 	// Comparisons
 	GT
@@ -50,104 +88,489 @@ const (
 	IN
 )
 
+// Position is a location in the source file. Line and Column start at 1,
+// Offset is the byte offset from the start of the file.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position points into a source file.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	s := p.Filename
+	if s == "" {
+		s = "<input>"
+	}
+	if p.IsValid() {
+		s += fmt.Sprintf(":%d:%d", p.Line, p.Column)
+	}
+	return s
+}
+
+func fromScanner(p scanner.Position) Position {
+	return Position{Filename: p.Filename, Offset: p.Offset, Line: p.Line, Column: p.Column}
+}
+
+// tokenNames holds the names of the token types used in error messages.
+var tokenNames = map[TokenType]string{
+	ILLEGAL:         "illegal token",
+	EOF:             "end of file",
+	COMMENT:         "comment",
+	IDENT:           "identifier",
+	INT:             "integer",
+	FLOAT:           "float",
+	STRING:          "string",
+	VAR:             "`lazy`",
+	CONST:           "`const`",
+	ONCE:            "`lazyOnce`",
+	IF:              "`if`",
+	ELSE:            "`el`",
+	FOR:             "`for`",
+	ARRAY:           "`lazyArray`",
+	WHILE:           "`while`",
+	PRINT:           "`lazyPrint`",
+	FUNCTION:        "`lazyFn`",
+	RETURN:          "`return`",
+	RECORD:          "`record`",
+	MATCH:           "`match`",
+	BREAK:           "`break`",
+	CONTINUE:        "`continue`",
+	TRUE:            "`true`",
+	FALSE:           "`false`",
+	PLUS:            "`+`",
+	MINUS:           "`-`",
+	MULTIPLY:        "`*`",
+	DIVIDE:          "`/`",
+	MODULO:          "`%`",
+	POWER:           "`**`",
+	ASSIGN:          "`=`",
+	ARROW:           "`=>`",
+	PLUS_ASSIGN:     "`+=`",
+	MINUS_ASSIGN:    "`-=`",
+	MULTIPLY_ASSIGN: "`*=`",
+	DIVIDE_ASSIGN:   "`/=`",
+	MODULO_ASSIGN:   "`%=`",
+	DECREMENT:       "`--`",
+	INCREMENT:       "`++`",
+	BANG:            "`!`",
+	AND:             "`&&`",
+	OR:              "`||`",
+	BIT_AND:         "`&`",
+	BIT_OR:          "`|`",
+	BIT_XOR:         "`^`",
+	SHL:             "`<<`",
+	SHR:             "`>>`",
+	LPAREN:          "`(`",
+	RPAREN:          "`)`",
+	LBRACE:          "`{`",
+	RBRACE:          "`}`",
+	LSBREC:          "`[`",
+	RSBREC:          "`]`",
+	SEMICOLON:       "`;`",
+	COLON:           "`:`",
+	COMMA:           "`,`",
+	DOT:             "`.`",
+	DOTDOT:          "`..`",
+	DOTDOT_EQ:       "`..=`",
+	GT:              "`>`",
+	LT:              "`<`",
+	GT_EQ:           "`>=`",
+	LT_EQ:           "`<=`",
+	EQ:              "`==`",
+	NOT_EQ:          "`!=`",
+	IN:              "`in`",
+}
+
+// String returns a human readable name for the token type, as used in
+// error messages.
+func (t TokenType) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+// Token is a single lexeme. Pos is the position of its first character and
+// End the position immediately after its last character.
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+	End     Position
+}
+
+// Describe returns a description of the token for error messages, such as
+// "identifier `x`" or "`=`".
+func (t Token) Describe() string {
+	switch t.Type {
+	case EOF:
+		return "end of file"
+	case IDENT, INT, FLOAT, STRING:
+		return fmt.Sprintf("%s `%s`", t.Type, t.Literal)
+	case ILLEGAL:
+		return fmt.Sprintf("illegal character `%s`", t.Literal)
+	default:
+		return t.Type.String()
+	}
+}
+
+// Error is a malformed token reported by the lexer.
+type Error struct {
+	Pos Position
+	End Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
 }
 
 // Lexer for tokenizing
 type Lexer struct {
-	scanner scanner.Scanner
-	token   rune
+	scanner  scanner.Scanner
+	token    rune
+	pending  []Token // tokens already read, returned before scanning again
+	errors   []*Error
+	comments []Token
 }
 
 func NewLexer(input string) *Lexer {
-	var s scanner.Scanner
-	s.Init(strings.NewReader(input))
-	s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats
-	return &Lexer{scanner: s, token: s.Scan()}
+	return NewFileLexer("", input)
+}
+
+// NewFileLexer is like NewLexer but records filename in every token position.
+func NewFileLexer(filename, input string) *Lexer {
+	l := &Lexer{}
+	l.scanner.Init(strings.NewReader(input))
+	l.scanner.Filename = filename
+	// Floats are scanned by scanNumber, see there
+	l.scanner.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanStrings |
+		scanner.ScanRawStrings | scanner.ScanComments
+	l.scanner.Error = l.scanError
+	l.token = l.scanner.Scan()
+	return l
+}
+
+// Errors returns the errors found in the tokens read so far.
+func (l *Lexer) Errors() []*Error {
+	return l.errors
+}
+
+// Comments returns the `//` and `/* */` comments read so far, in source order.
+// NextToken skips comments, so this is the only way to get at them.
+func (l *Lexer) Comments() []Token {
+	return l.comments
+}
+
+func (l *Lexer) scanError(s *scanner.Scanner, msg string) {
+	pos := s.Position
+	if !pos.IsValid() {
+		pos = s.Pos()
+	}
+	l.errors = append(l.errors, &Error{
+		Pos: fromScanner(pos),
+		End: fromScanner(s.Pos()),
+		Msg: msg,
+	})
+}
+
+func (l *Lexer) errorAt(pos, end Position, msg string) {
+	l.errors = append(l.errors, &Error{Pos: pos, End: end, Msg: msg})
 }
 
 func (l *Lexer) NextToken() Token {
+	if len(l.pending) > 0 {
+		tok := l.pending[0]
+		l.pending = l.pending[1:]
+		return tok
+	}
+
+	for l.token == scanner.Comment {
+		l.comments = append(l.comments, Token{
+			Type:    COMMENT,
+			Literal: l.scanner.TokenText(),
+			Pos:     fromScanner(l.scanner.Position),
+			End:     fromScanner(l.scanner.Pos()),
+		})
+		l.token = l.scanner.Scan()
+	}
+
 	var tok Token
+	pos := fromScanner(l.scanner.Position)
 
 	switch l.token {
 	case scanner.EOF:
-		tok = Token{EOF, ""}
+		tok = Token{Type: EOF, Literal: ""}
 	case scanner.Ident:
 		literal := l.scanner.TokenText()
 		switch literal {
 		case "lazy":
-			tok = Token{VAR, literal}
+			tok = Token{Type: VAR, Literal: literal}
+		case "const":
+			tok = Token{Type: CONST, Literal: literal}
+		case "lazyOnce":
+			tok = Token{Type: ONCE, Literal: literal}
 		case "lazyArray":
-			tok = Token{ARRAY, literal}
+			tok = Token{Type: ARRAY, Literal: literal}
 		case "if":
-			tok = Token{IF, literal}
+			tok = Token{Type: IF, Literal: literal}
 		case "el":
-			tok = Token{ELSE, literal}
+			tok = Token{Type: ELSE, Literal: literal}
 		case "lazyPrint":
-			tok = Token{PRINT, literal}
+			tok = Token{Type: PRINT, Literal: literal}
 		case "for":
-			tok = Token{FOR, literal}
+			tok = Token{Type: FOR, Literal: literal}
+		case "lazyFn":
+			tok = Token{Type: FUNCTION, Literal: literal}
+		case "return":
+			tok = Token{Type: RETURN, Literal: literal}
+		case "record":
+			tok = Token{Type: RECORD, Literal: literal}
+		case "true":
+			tok = Token{Type: TRUE, Literal: literal}
+		case "false":
+			tok = Token{Type: FALSE, Literal: literal}
 		case "while":
-			tok = Token{WHILE, literal}
+			tok = Token{Type: WHILE, Literal: literal}
 		case "in":
-			tok = Token{IN, literal}
+			tok = Token{Type: IN, Literal: literal}
+		case "match":
+			tok = Token{Type: MATCH, Literal: literal}
+		case "break":
+			tok = Token{Type: BREAK, Literal: literal}
+		case "continue":
+			tok = Token{Type: CONTINUE, Literal: literal}
 		default:
-			tok = Token{IDENT, literal}
+			tok = Token{Type: IDENT, Literal: literal}
 		}
-	case scanner.Int, scanner.Float:
-		tok = Token{NUMBER, l.scanner.TokenText()}
+	case scanner.Int:
+		tok = l.scanNumber(pos)
+	case scanner.String, scanner.RawString:
+		tok = Token{Type: STRING, Literal: l.scanner.TokenText()}
 	case '+':
-		tok = Token{PLUS, "+"}
+		switch l.scanner.Peek() {
+		case '+':
+			l.scanner.Next() // consume the second '+'
+			tok = Token{Type: INCREMENT, Literal: "++"}
+		case '=':
+			l.scanner.Next() // consume the '='
+			tok = Token{Type: PLUS_ASSIGN, Literal: "+="}
+		default:
+			tok = Token{Type: PLUS, Literal: "+"}
+		}
 	case '-':
-		tok = Token{MINUS, "-"}
+		switch l.scanner.Peek() {
+		case '-':
+			l.scanner.Next() // consume the second '-'
+			tok = Token{Type: DECREMENT, Literal: "--"}
+		case '=':
+			l.scanner.Next() // consume the '='
+			tok = Token{Type: MINUS_ASSIGN, Literal: "-="}
+		default:
+			tok = Token{Type: MINUS, Literal: "-"}
+		}
 	case '*':
-		tok = Token{MULTIPLY, "*"}
+		switch l.scanner.Peek() {
+		case '*':
+			l.scanner.Next() // consume the second '*'
+			tok = Token{Type: POWER, Literal: "**"}
+		case '=':
+			l.scanner.Next() // consume the '='
+			tok = Token{Type: MULTIPLY_ASSIGN, Literal: "*="}
+		default:
+			tok = Token{Type: MULTIPLY, Literal: "*"}
+		}
 	case '/':
-		tok = Token{DIVIDE, "/"}
+		if l.scanner.Peek() == '=' {
+			l.scanner.Next() // consume the '='
+			tok = Token{Type: DIVIDE_ASSIGN, Literal: "/="}
+		} else {
+			tok = Token{Type: DIVIDE, Literal: "/"}
+		}
+	case '%':
+		if l.scanner.Peek() == '=' {
+			l.scanner.Next() // consume the '='
+			tok = Token{Type: MODULO_ASSIGN, Literal: "%="}
+		} else {
+			tok = Token{Type: MODULO, Literal: "%"}
+		}
+	case '^':
+		tok = Token{Type: BIT_XOR, Literal: "^"}
 	case '=':
 		next := l.scanner.Peek()
 		if next == '=' {
-			l.scanner.Next()      // consume the second '='
-			tok = Token{EQ, "=="} // now we have a '==' token
+			l.scanner.Next()                     // consume the second '='
+			tok = Token{Type: EQ, Literal: "=="} // now we have a '==' token
+		} else if next == '>' {
+			l.scanner.Next() // consume the '>'
+			tok = Token{Type: ARROW, Literal: "=>"}
 		} else {
-			tok = Token{ASSIGN, "="}
+			tok = Token{Type: ASSIGN, Literal: "="}
 		}
 	case '(':
-		tok = Token{LPAREN, "("}
+		tok = Token{Type: LPAREN, Literal: "("}
 	case ')':
-		tok = Token{RPAREN, ")"}
+		tok = Token{Type: RPAREN, Literal: ")"}
 	case '{':
-		tok = Token{LBRACE, "{"}
+		tok = Token{Type: LBRACE, Literal: "{"}
 	case '[':
-		tok = Token{LSBREC, "["}
+		tok = Token{Type: LSBREC, Literal: "["}
 	case ']':
-		tok = Token{RSBREC, "]"}
+		tok = Token{Type: RSBREC, Literal: "]"}
 	case ';':
-		tok = Token{SEMICOLON, ";"}
+		tok = Token{Type: SEMICOLON, Literal: ";"}
+	case ':':
+		tok = Token{Type: COLON, Literal: ":"}
 	case ',':
-		tok = Token{COMMA, ","}
+		tok = Token{Type: COMMA, Literal: ","}
+	case '.':
+		switch {
+		case l.scanner.Peek() == '.':
+			tok = l.scanRange()
+		case isDigit(l.scanner.Peek()):
+			literal := "."
+			l.scanDigits(&literal)
+			tok = Token{Type: FLOAT, Literal: literal}
+		default:
+			tok = Token{Type: DOT, Literal: "."}
+		}
 	case '}':
-		tok = Token{RBRACE, "}"}
+		tok = Token{Type: RBRACE, Literal: "}"}
 	case '>':
-		if l.scanner.Peek() == '=' {
+		switch l.scanner.Peek() {
+		case '=':
 			l.scanner.Next() // consume the '='
-			tok = Token{GT_EQ, ">="}
-		} else {
-			tok = Token{GT, ">"}
+			tok = Token{Type: GT_EQ, Literal: ">="}
+		case '>':
+			l.scanner.Next() // consume the second '>'
+			tok = Token{Type: SHR, Literal: ">>"}
+		default:
+			tok = Token{Type: GT, Literal: ">"}
 		}
 	case '<':
+		switch l.scanner.Peek() {
+		case '=':
+			l.scanner.Next() // consume the '='
+			tok = Token{Type: LT_EQ, Literal: "<="}
+		case '<':
+			l.scanner.Next() // consume the second '<'
+			tok = Token{Type: SHL, Literal: "<<"}
+		default:
+			tok = Token{Type: LT, Literal: "<"}
+		}
+	case '!':
 		if l.scanner.Peek() == '=' {
 			l.scanner.Next() // consume the '='
-			tok = Token{LT_EQ, "<="}
+			tok = Token{Type: NOT_EQ, Literal: "!="}
 		} else {
-			tok = Token{LT, "<"}
+			tok = Token{Type: BANG, Literal: "!"}
+		}
+	case '&':
+		if l.scanner.Peek() == '&' {
+			l.scanner.Next() // consume the second '&'
+			tok = Token{Type: AND, Literal: "&&"}
+		} else {
+			tok = Token{Type: BIT_AND, Literal: "&"}
+		}
+	case '|':
+		if l.scanner.Peek() == '|' {
+			l.scanner.Next() // consume the second '|'
+			tok = Token{Type: OR, Literal: "||"}
+		} else {
+			tok = Token{Type: BIT_OR, Literal: "|"}
 		}
 	default:
-		tok = Token{ILLEGAL, l.scanner.TokenText()}
+		tok = Token{Type: ILLEGAL, Literal: l.scanner.TokenText()}
 	}
 
+	tok.Pos = pos
+	if !tok.End.IsValid() {
+		tok.End = fromScanner(l.scanner.Pos())
+	}
 	l.token = l.scanner.Scan()
 	return tok
 }
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// scanDigits appends the decimal digits that follow to literal and reports
+// whether there were any.
+func (l *Lexer) scanDigits(literal *string) bool {
+	found := false
+	for isDigit(l.scanner.Peek()) || l.scanner.Peek() == '_' {
+		*literal += string(l.scanner.Next())
+		found = true
+	}
+	return found
+}
+
+// separated reports whether every '_' in a number literal is between two
+// digits.
+func separated(literal string) bool {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] == '_' && (i == 0 || i == len(literal)-1 || !isDigit(rune(literal[i-1])) || !isDigit(rune(literal[i+1]))) {
+			return false
+		}
+	}
+	return true
+}
+
+// scanRange scans `..` or `..=` after the scanner read the first `.`.
+func (l *Lexer) scanRange() Token {
+	l.scanner.Next() // consume the second '.'
+	if l.scanner.Peek() == '=' {
+		l.scanner.Next() // consume the '='
+		return Token{Type: DOTDOT_EQ, Literal: "..="}
+	}
+	return Token{Type: DOTDOT, Literal: ".."}
+}
+
+// scanNumber finishes a number whose integer part the scanner just read. The
+// scanner does not scan floats itself, because it would read the `0.` of the
+// range 0..10 as a float: here a `.` only starts a fraction if it is not
+// followed by a second `.`.
+func (l *Lexer) scanNumber(pos Position) Token {
+	literal := l.scanner.TokenText()
+	if len(literal) > 1 && literal[0] == '0' && strings.ContainsAny(literal[1:2], "xXbBoO") {
+		return Token{Type: INT, Literal: literal}
+	}
+
+	typ := INT
+	if l.scanner.Peek() == '.' {
+		dot := fromScanner(l.scanner.Pos())
+		l.scanner.Next() // consume the '.'
+		if l.scanner.Peek() == '.' {
+			// The integer is the start of a range, the `..` comes next
+			rangeTok := l.scanRange()
+			rangeTok.Pos = dot
+			rangeTok.End = fromScanner(l.scanner.Pos())
+			l.pending = append(l.pending, rangeTok)
+			return Token{Type: INT, Literal: literal, End: dot}
+		}
+		typ = FLOAT
+		literal += "."
+		l.scanDigits(&literal)
+	}
+
+	if p := l.scanner.Peek(); p == 'e' || p == 'E' {
+		typ = FLOAT
+		literal += string(l.scanner.Next())
+		if p := l.scanner.Peek(); p == '+' || p == '-' {
+			literal += string(l.scanner.Next())
+		}
+		if !l.scanDigits(&literal) {
+			l.errorAt(pos, fromScanner(l.scanner.Pos()), "exponent has no digits")
+		}
+	}
+	if typ == FLOAT && !separated(literal) {
+		l.errorAt(pos, fromScanner(l.scanner.Pos()), "'_' must separate successive digits")
+	}
+
+	return Token{Type: typ, Literal: literal}
+}
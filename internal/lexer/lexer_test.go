@@ -0,0 +1,59 @@
+package lexer
+
+import "testing"
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		typ   TokenType
+		err   bool
+	}{
+		{"1_000", INT, false},
+		{"1_000.5", FLOAT, false},
+		{"1_000.5e1_0", FLOAT, false},
+		{"1.0", FLOAT, false},
+		{"2e3", FLOAT, false},
+		{"0x1_F", INT, false},
+		{"1.5_", FLOAT, true},
+		{"1._5", FLOAT, true},
+		{"1.5e_1", FLOAT, true},
+		{"1e", FLOAT, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := NewLexer(tt.input)
+			tok := l.NextToken()
+			if tok.Type != tt.typ || tok.Literal != tt.input {
+				t.Errorf("token is %s %q, want %s %q", tok.Type, tok.Literal, tt.typ, tt.input)
+			}
+			if got := len(l.Errors()) > 0; got != tt.err {
+				t.Errorf("errors: %v, want error: %v", l.Errors(), tt.err)
+			}
+		})
+	}
+}
+
+func TestPositions(t *testing.T) {
+	l := NewFileLexer("a.lazy", "lazy x = 10\n  x += 1.5")
+	tests := []struct {
+		typ        TokenType
+		start, end string
+	}{
+		{VAR, "a.lazy:1:1", "a.lazy:1:5"},
+		{IDENT, "a.lazy:1:6", "a.lazy:1:7"},
+		{ASSIGN, "a.lazy:1:8", "a.lazy:1:9"},
+		{INT, "a.lazy:1:10", "a.lazy:1:12"},
+		{IDENT, "a.lazy:2:3", "a.lazy:2:4"},
+		{PLUS_ASSIGN, "a.lazy:2:5", "a.lazy:2:7"},
+		{FLOAT, "a.lazy:2:8", "a.lazy:2:11"},
+	}
+	for _, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.typ || tok.Pos.String() != tt.start || tok.End.String() != tt.end {
+			t.Errorf("got %s at %s-%s, want %s at %s-%s", tok.Type, tok.Pos, tok.End, tt.typ, tt.start, tt.end)
+		}
+	}
+	if tok := l.NextToken(); tok.Type != EOF {
+		t.Errorf("got %s, want EOF", tok.Type)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"log"
 	"os"
 
+	"github.com/lazydiv/lazyLang-compiler/internal/checker"
+	diag "github.com/lazydiv/lazyLang-compiler/internal/diagnostics"
 	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
 	"github.com/lazydiv/lazyLang-compiler/internal/parser"
 	"github.com/sourcegraph/jsonrpc2"
@@ -61,20 +63,42 @@ func runDiagnostics(source string) []map[string]interface{} {
 	p := parser.NewParser(lex)
 	prog := p.ParseProgram()
 
-	// If the program is empty or parsing failed, return a syntax error.
-	if prog == nil || len(prog.Statements) == 0 {
-		return []map[string]interface{}{
-			{
-				"range": map[string]interface{}{
-					"start": map[string]int{"line": 0, "character": 0},
-					"end":   map[string]int{"line": 0, "character": 1},
-				},
-				"severity": 1,
-				"message":  "Syntax error",
-			},
+	diagnostics := []map[string]interface{}{}
+	for _, err := range lex.Errors() {
+		diagnostics = append(diagnostics, lspDiagnostic(err.Pos, err.End, err.Msg))
+	}
+	for _, err := range p.Errors() {
+		diagnostics = append(diagnostics, lspDiagnostic(err.Pos, err.End, err.Msg))
+	}
+
+	// Semantic errors only make sense once the program parses
+	if len(diagnostics) == 0 {
+		for _, d := range checker.NewChecker().Check(prog) {
+			item := lspDiagnostic(d.Pos, d.End, d.Message)
+			if d.Severity == diag.Warning {
+				item["severity"] = 2
+			}
+			diagnostics = append(diagnostics, item)
 		}
 	}
-	return []map[string]interface{}{}
+	return diagnostics
+}
+
+// lspDiagnostic builds an error diagnostic for the range pos..end.
+func lspDiagnostic(pos, end lexer.Position, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"range": map[string]interface{}{
+			"start": lspPosition(pos),
+			"end":   lspPosition(end),
+		},
+		"severity": 1,
+		"message":  message,
+	}
+}
+
+// lspPosition converts a 1-based lexer position to a 0-based LSP position.
+func lspPosition(pos lexer.Position) map[string]int {
+	return map[string]int{"line": pos.Line - 1, "character": pos.Column - 1}
 }
 
 func main() {
@@ -4,12 +4,25 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
 )
 
 type Node interface {
 	String() string
+	Pos() lexer.Position // position of the first character of the node
+	End() lexer.Position // position immediately after the node
+}
+
+// Span is embedded in every node to record its source range.
+type Span struct {
+	StartPos lexer.Position
+	EndPos   lexer.Position
 }
 
+func (s Span) Pos() lexer.Position { return s.StartPos }
+func (s Span) End() lexer.Position { return s.EndPos }
+
 type Statement interface {
 	Node
 	statementNode()
@@ -34,23 +47,66 @@ func (p *Program) String() string {
 }
 
 type VarStatement struct {
+	Span
 	Name  string
+	Type  *TypeExpr // optional annotation: lazy x float = 1
 	Value Expression
 }
 
 type ArrayStatement struct {
+	Span
 	Name   string
+	Type   *TypeExpr // optional annotation: lazyArray xs []int = []
 	Values []Expression
 }
 
+// ConstStatement declares a name that cannot be assigned again:
+// const limit = 10. Unlike `lazy` it always declares a new name.
+type ConstStatement struct {
+	Span
+	Name  string
+	Type  *TypeExpr // optional annotation
+	Value Expression
+}
+
+func (cs *ConstStatement) statementNode() {}
+func (cs *ConstStatement) String() string {
+	if cs.Type != nil {
+		return fmt.Sprintf("const %s %s = %s", cs.Name, cs.Type.String(), cs.Value.String())
+	}
+	return fmt.Sprintf("const %s = %s", cs.Name, cs.Value.String())
+}
+
+// LazyStatement declares a name whose value is not computed until the name
+// is first used, and then only once: lazyOnce total = sum(nums). Like a
+// constant it cannot be assigned again.
+type LazyStatement struct {
+	Span
+	Name  string
+	Type  *TypeExpr // optional annotation
+	Value Expression
+}
+
+func (ls *LazyStatement) statementNode() {}
+func (ls *LazyStatement) String() string {
+	if ls.Type != nil {
+		return fmt.Sprintf("lazyOnce %s %s = %s", ls.Name, ls.Type.String(), ls.Value.String())
+	}
+	return fmt.Sprintf("lazyOnce %s = %s", ls.Name, ls.Value.String())
+}
+
 func (vs *VarStatement) statementNode() {}
 
 func (vs *VarStatement) String() string {
+	if vs.Type != nil {
+		return fmt.Sprintf("var %s %s = %s", vs.Name, vs.Type.String(), vs.Value.String())
+	}
 	return fmt.Sprintf("var %s = %s", vs.Name, vs.Value.String())
 }
 
 // IndexExpression represents accessing an array element by index: array[index]
 type IndexExpression struct {
+	Span
 	Array Expression
 	Index Expression
 }
@@ -61,11 +117,42 @@ func (ie *IndexExpression) String() string {
 
 }
 
+// FieldExpression selects a field of a record: p.x
+type FieldExpression struct {
+	Span
+	Record Expression
+	Field  *Identifier
+}
+
+func (fe *FieldExpression) expressionNode() {}
+func (fe *FieldExpression) String() string {
+	return fe.Record.String() + "." + fe.Field.Value
+}
+
+// ArrayLiteral is an array value: [1, 2, 3]. Elements may be arrays
+// themselves, as in [[1, 2], [3, 4]].
+type ArrayLiteral struct {
+	Span
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) String() string {
+	elements := make([]string, len(al.Elements))
+	for i, e := range al.Elements {
+		elements[i] = e.String()
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
 func (as *ArrayStatement) statementNode() {}
 func (as *ArrayStatement) String() string {
 	var out strings.Builder
-	out.WriteString("lazyArray")
+	out.WriteString("lazyArray ")
 	out.WriteString(as.Name)
+	if as.Type != nil {
+		out.WriteString(" " + as.Type.String())
+	}
 	out.WriteString(" = [")
 	for i, v := range as.Values {
 		if i != 0 {
@@ -78,12 +165,15 @@ func (as *ArrayStatement) String() string {
 }
 
 type IfStatement struct {
+	Span
 	Condition   Expression
 	Consequence []Statement
 	Alternative []Statement
 }
 
 type ForStatement struct {
+	Span
+	Label     *Identifier // optional: outer: for (...) { }
 	Init      Statement   // Initialization statement
 	Condition Expression  // Loop condition
 	Post      Statement   // Post iteration statement
@@ -115,7 +205,7 @@ func (is *IfStatement) String() string {
 func (fs *ForStatement) statementNode() {}
 func (fs *ForStatement) String() string {
 	var out strings.Builder
-	out.WriteString("for ")
+	out.WriteString(labelString(fs.Label) + "for ")
 
 	if fs.Init != nil {
 		out.WriteString(fs.Init.String() + "; ")
@@ -135,30 +225,392 @@ func (fs *ForStatement) String() string {
 	return out.String()
 }
 
-type PrintStatement struct {
+// MatchStatement runs the first arm with a pattern equal to Value:
+//
+//	match n {
+//	  1, 2 => lazyPrint("small")
+//	  _ => lazyPrint("large")
+//	}
+type MatchStatement struct {
+	Span
 	Value Expression
+	Arms  []*MatchArm
+}
+
+// MatchArm is one arm of a match. Patterns is empty for the `_` arm, which
+// matches every value.
+type MatchArm struct {
+	Span
+	Patterns []Expression
+	Body     []Statement
+}
+
+func (ms *MatchStatement) statementNode() {}
+func (ms *MatchStatement) String() string {
+	var out strings.Builder
+	out.WriteString("match " + ms.Value.String() + " { ")
+	for _, arm := range ms.Arms {
+		out.WriteString(arm.String() + "; ")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	var out strings.Builder
+	if len(ma.Patterns) == 0 {
+		out.WriteString("_")
+	}
+	for i, pattern := range ma.Patterns {
+		if i != 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(pattern.String())
+	}
+	out.WriteString(" => { ")
+	for _, stmt := range ma.Body {
+		out.WriteString(stmt.String() + "; ")
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+// ForInStatement runs Body once for every element of an array or number of
+// a range: for x in nums, for i, x in nums, for i in 0..10. Index is nil when
+// only one variable is given.
+type ForInStatement struct {
+	Span
+	Label    *Identifier // optional
+	Index    *Identifier
+	Value    *Identifier
+	Iterable Expression // an array or a *RangeExpression
+	Body     []Statement
+}
+
+func (fs *ForInStatement) statementNode() {}
+func (fs *ForInStatement) String() string {
+	var out strings.Builder
+	out.WriteString(labelString(fs.Label) + "for ")
+	if fs.Index != nil {
+		out.WriteString(fs.Index.String() + ", ")
+	}
+	out.WriteString(fs.Value.String() + " in " + fs.Iterable.String())
+	out.WriteString(" { ")
+	for _, stmt := range fs.Body {
+		out.WriteString(stmt.String() + "; ")
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// RangeExpression is the range of ints from From up to To, which is
+// excluded unless Inclusive is set: 0..10, 0..=10.
+type RangeExpression struct {
+	Span
+	From      Expression
+	To        Expression
+	Inclusive bool
+}
+
+func (re *RangeExpression) expressionNode() {}
+func (re *RangeExpression) String() string {
+	if re.Inclusive {
+		return re.From.String() + "..=" + re.To.String()
+	}
+	return re.From.String() + ".." + re.To.String()
+}
+
+func labelString(label *Identifier) string {
+	if label == nil {
+		return ""
+	}
+	return label.Value + ": "
+}
+
+// BranchStatement leaves a loop early: Keyword is "break" or "continue", and
+// Label names the loop if it is not the innermost one.
+type BranchStatement struct {
+	Span
+	Keyword string
+	Label   *Identifier
+}
+
+func (bs *BranchStatement) statementNode() {}
+func (bs *BranchStatement) String() string {
+	if bs.Label != nil {
+		return bs.Keyword + " " + bs.Label.Value
+	}
+	return bs.Keyword
+}
+
+// WhileStatement repeats Body as long as Condition holds.
+type WhileStatement struct {
+	Span
+	Label     *Identifier // optional
+	Condition Expression
+	Body      []Statement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) String() string {
+	var out strings.Builder
+	out.WriteString(labelString(ws.Label) + "while ")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" { ")
+	for _, stmt := range ws.Body {
+		out.WriteString(stmt.String() + "; ")
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+// TypeExpr is a type annotation such as `int` or `[]string`.
+type TypeExpr struct {
+	Span
+	Name string    // type name, empty for array types
+	Elem *TypeExpr // element type of an array type
+}
+
+func (te *TypeExpr) String() string {
+	if te.Elem != nil {
+		return "[]" + te.Elem.String()
+	}
+	return te.Name
+}
+
+// Parameter is a single typed function parameter.
+type Parameter struct {
+	Span
+	Name string
+	Type *TypeExpr
+}
+
+func (pa *Parameter) String() string {
+	return fmt.Sprintf("%s %s", pa.Name, pa.Type.String())
+}
+
+// FunctionStatement declares a function: lazyFn name(a int, b int) int { ... }
+// ReturnType is nil for functions that do not return a value.
+type FunctionStatement struct {
+	Span
+	Name       string
+	Parameters []*Parameter
+	ReturnType *TypeExpr
+	Body       []Statement
+}
+
+func (fs *FunctionStatement) statementNode() {}
+func (fs *FunctionStatement) String() string {
+	var out strings.Builder
+	params := make([]string, len(fs.Parameters))
+	for i, p := range fs.Parameters {
+		params[i] = p.String()
+	}
+	out.WriteString(fmt.Sprintf("lazyFn %s(%s)", fs.Name, strings.Join(params, ", ")))
+	if fs.ReturnType != nil {
+		out.WriteString(" " + fs.ReturnType.String())
+	}
+	out.WriteString(" { ")
+	for _, stmt := range fs.Body {
+		out.WriteString(stmt.String() + "; ")
+	}
+	out.WriteString(" }")
+	return out.String()
+}
+
+// RecordStatement declares a record type, a group of named fields:
+// record Point { x, y }. A field without a type takes the type of its
+// value in the first construction, Point(1, 2).
+type RecordStatement struct {
+	Span
+	Name   string
+	Fields []*RecordField
+}
+
+// RecordField is one field of a record. Type is nil if it is not annotated.
+type RecordField struct {
+	Span
+	Name string
+	Type *TypeExpr
+}
+
+func (rf *RecordField) String() string {
+	if rf.Type != nil {
+		return rf.Name + " " + rf.Type.String()
+	}
+	return rf.Name
+}
+
+func (rs *RecordStatement) statementNode() {}
+func (rs *RecordStatement) String() string {
+	fields := make([]string, len(rs.Fields))
+	for i, f := range rs.Fields {
+		fields[i] = f.String()
+	}
+	return fmt.Sprintf("record %s { %s }", rs.Name, strings.Join(fields, ", "))
+}
+
+// ReturnStatement leaves the enclosing function. Value is nil for a bare return.
+type ReturnStatement struct {
+	Span
+	Value Expression
+}
+
+func (rs *ReturnStatement) statementNode() {}
+func (rs *ReturnStatement) String() string {
+	if rs.Value == nil {
+		return "return"
+	}
+	return "return " + rs.Value.String()
+}
+
+// AssignStatement stores a new value in an existing variable or array
+// element: x = 1. Unlike VarStatement it never declares anything.
+type AssignStatement struct {
+	Span
+	Target Expression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode() {}
+func (as *AssignStatement) String() string {
+	return fmt.Sprintf("%s = %s", as.Target.String(), as.Value.String())
+}
+
+// CompoundAssignStatement updates a variable or array element in place:
+// total += x. Operator includes the `=`.
+type CompoundAssignStatement struct {
+	Span
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (cs *CompoundAssignStatement) statementNode() {}
+func (cs *CompoundAssignStatement) String() string {
+	return fmt.Sprintf("%s %s %s", cs.Target.String(), cs.Operator, cs.Value.String())
+}
+
+// IncDecStatement adds or subtracts one: i++, i--.
+type IncDecStatement struct {
+	Span
+	Target   Expression
+	Operator string
+}
+
+func (is *IncDecStatement) statementNode() {}
+func (is *IncDecStatement) String() string {
+	return is.Target.String() + is.Operator
+}
+
+// ExpressionStatement is an expression used as a statement, such as a call
+// whose result is discarded.
+type ExpressionStatement struct {
+	Span
+	Expression Expression
+}
+
+func (es *ExpressionStatement) statementNode() {}
+func (es *ExpressionStatement) String() string {
+	return es.Expression.String()
+}
+
+type PrintStatement struct {
+	Span
+	Values []Expression
 }
 
 func (ps *PrintStatement) statementNode() {}
 func (ps *PrintStatement) String() string {
-	return fmt.Sprintf("print(%s)", ps.Value.String())
+	values := make([]string, len(ps.Values))
+	for i, v := range ps.Values {
+		values[i] = v.String()
+	}
+	return fmt.Sprintf("print(%s)", strings.Join(values, ", "))
 }
 
 type Identifier struct {
+	Span
 	Value string
 }
 
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) String() string  { return i.Value }
 
-type NumberLiteral struct {
+type IntegerLiteral struct {
+	Span
+	Value int64
+}
+
+func (il *IntegerLiteral) expressionNode() {}
+func (il *IntegerLiteral) String() string  { return strconv.FormatInt(il.Value, 10) }
+
+type FloatLiteral struct {
+	Span
 	Value float64
 }
 
-func (nl *NumberLiteral) expressionNode() {}
-func (nl *NumberLiteral) String() string  { return strconv.FormatFloat(nl.Value, 'f', -1, 64) }
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) String() string  { return FormatFloat(fl.Value) }
+
+// FormatFloat formats f so that it always reads as a float literal: 1 is
+// written as 1.0, so it never turns into an integer in generated code.
+func FormatFloat(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEIN") {
+		s += ".0"
+	}
+	return s
+}
+
+type BooleanLiteral struct {
+	Span
+	Value bool
+}
+
+func (bl *BooleanLiteral) expressionNode() {}
+func (bl *BooleanLiteral) String() string  { return strconv.FormatBool(bl.Value) }
+
+// StringLiteral holds the decoded value of a "..." or `...` literal.
+type StringLiteral struct {
+	Span
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode() {}
+func (sl *StringLiteral) String() string  { return strconv.Quote(sl.Value) }
+
+// CallExpression calls Function with Arguments: name(a, b)
+type CallExpression struct {
+	Span
+	Function  Expression
+	Arguments []Expression
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) String() string {
+	args := make([]string, len(ce.Arguments))
+	for i, a := range ce.Arguments {
+		args[i] = a.String()
+	}
+	return fmt.Sprintf("%s(%s)", ce.Function.String(), strings.Join(args, ", "))
+}
+
+// PrefixExpression applies a unary operator: !ok, -x
+type PrefixExpression struct {
+	Span
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode() {}
+func (pe *PrefixExpression) String() string {
+	return fmt.Sprintf("(%s%s)", pe.Operator, pe.Right.String())
+}
 
 type InfixExpression struct {
+	Span
 	Left     Expression
 	Operator string
 	Right    Expression
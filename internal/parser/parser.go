@@ -1,15 +1,31 @@
 package parser
 
 import (
-	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+	"fmt"
 	"strconv"
+	"strings"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
 )
 
+// Error is a syntax error reported by the parser.
+type Error struct {
+	Pos lexer.Position
+	End lexer.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
 // Parser builds the AST
 type Parser struct {
-	lexer        *lexer.Lexer
-	currentToken lexer.Token
-	peekToken    lexer.Token
+	lexer         *lexer.Lexer
+	previousToken lexer.Token // token before currentToken, for errors at the end of a line
+	currentToken  lexer.Token
+	peekToken     lexer.Token
+	errors        []*Error
 }
 
 func NewParser(l *lexer.Lexer) *Parser {
@@ -20,10 +36,16 @@ func NewParser(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	p.previousToken = p.currentToken
 	p.currentToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
 }
 
+// span returns the range from start to the end of the current token.
+func (p *Parser) span(start lexer.Position) Span {
+	return Span{StartPos: start, EndPos: p.currentToken.End}
+}
+
 func (p *Parser) expectCurrent(t lexer.TokenType) bool {
 	return p.currentToken.Type == t
 }
@@ -33,43 +55,135 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 		p.nextToken()
 		return true
 	}
+	p.peekError(t)
 	return false
 }
 
+// Errors returns the syntax errors found so far, in source order.
+func (p *Parser) Errors() []*Error {
+	return p.errors
+}
+
+func (p *Parser) errorAt(tok lexer.Token, format string, args ...interface{}) {
+	p.errorRange(tok.Pos, tok.End, format, args...)
+}
+
+func (p *Parser) errorRange(pos, end lexer.Position, format string, args ...interface{}) {
+	// Only the first error at a given position is useful, the rest are
+	// usually follow-on errors from recovery.
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos == pos {
+		return
+	}
+	p.errors = append(p.errors, &Error{
+		Pos: pos,
+		End: end,
+		Msg: fmt.Sprintf(format, args...),
+	})
+}
+
+func (p *Parser) peekError(t lexer.TokenType) {
+	p.errorAt(p.peekToken, "expected %s after %s, got %s", t, p.currentToken.Describe(), p.peekToken.Describe())
+}
+
 func (p *Parser) ParseProgram() *Program {
 	program := &Program{Statements: []Statement{}}
 
 	for p.currentToken.Type != lexer.EOF {
+		start := p.currentToken.Pos
 		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		if stmt == nil {
+			p.synchronize(start)
+			continue
 		}
+		program.Statements = append(program.Statements, stmt)
 		p.nextToken()
 	}
 
 	return program
 }
 
+// isStatementStart reports whether a statement can begin with t.
+func isStatementStart(t lexer.TokenType) bool {
+	switch t {
+	case lexer.VAR, lexer.CONST, lexer.ONCE, lexer.ARRAY, lexer.IF, lexer.FOR, lexer.WHILE, lexer.PRINT, lexer.FUNCTION, lexer.RETURN, lexer.MATCH,
+		lexer.RECORD, lexer.BREAK, lexer.CONTINUE:
+		return true
+	default:
+		return false
+	}
+}
+
+// synchronize recovers from a syntax error in the statement that began at
+// start. It skips tokens until the next statement keyword, a closing brace or
+// the first token on a new line, so that parsing can continue and report
+// further errors. Blocks opened while skipping are skipped as a whole.
+func (p *Parser) synchronize(start lexer.Position) {
+	depth := 0
+	line := start.Line
+	for p.currentToken.Type != lexer.EOF {
+		tok := p.currentToken
+		if depth == 0 && tok.Pos != start {
+			if tok.Type == lexer.RBRACE || isStatementStart(tok.Type) || tok.Pos.Line > line {
+				return
+			}
+		}
+
+		switch tok.Type {
+		case lexer.LBRACE:
+			depth++
+		case lexer.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+		line = tok.End.Line
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() Statement {
 	switch p.currentToken.Type {
 	case lexer.VAR:
 		return p.parseVarStatement()
+	case lexer.CONST:
+		return p.parseConstStatement()
+	case lexer.ONCE:
+		return p.parseLazyStatement()
 	case lexer.ARRAY:
 		return p.parseArray()
 
 	case lexer.FOR:
 		return p.parseForStatement()
+	case lexer.WHILE:
+		return p.parseWhileStatement()
 	case lexer.IF:
 		return p.parseIfStatement()
+	case lexer.MATCH:
+		return p.parseMatchStatement()
 	case lexer.PRINT:
 		return p.parsePrintStatement()
+	case lexer.FUNCTION:
+		return p.parseFunctionStatement()
+	case lexer.RECORD:
+		return p.parseRecordStatement()
+	case lexer.RETURN:
+		return p.parseReturnStatement()
+	case lexer.BREAK, lexer.CONTINUE:
+		return p.parseBranchStatement()
+	case lexer.IDENT:
+		if p.peekToken.Type == lexer.COLON {
+			return p.parseLabeledLoop()
+		}
+		return p.parseSimpleStatement()
 	default:
+		p.errorAt(p.currentToken, "unexpected %s at start of statement", p.currentToken.Describe())
 		return nil
 	}
 }
 
-func (p *Parser) parseArray() *ArrayStatement {
+func (p *Parser) parseArray() Statement {
 	stmt := &ArrayStatement{}
+	start := p.currentToken.Pos
 
 	// After 'lazyArray', expect an identifier
 	if !p.expectPeek(lexer.IDENT) {
@@ -78,6 +192,14 @@ func (p *Parser) parseArray() *ArrayStatement {
 
 	stmt.Name = p.currentToken.Literal
 
+	if p.peekToken.Type != lexer.ASSIGN {
+		p.nextToken()
+		stmt.Type = p.parseType()
+		if stmt.Type == nil {
+			return nil
+		}
+	}
+
 	// Expect assignment operator
 	if !p.expectPeek(lexer.ASSIGN) {
 		return nil
@@ -88,29 +210,52 @@ func (p *Parser) parseArray() *ArrayStatement {
 		return nil
 	}
 
-	// Parse array elements
-	values := []Expression{}
+	// Parse array elements up to the closing bracket
+	values, ok := p.parseExpressionList(lexer.RSBREC)
+	if !ok {
+		return nil
+	}
+
+	stmt.Values = values
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// parseExpressionList parses a comma separated list of expressions closed by
+// end. The current token is the opening delimiter; on success the current
+// token is end.
+func (p *Parser) parseExpressionList(end lexer.TokenType) ([]Expression, bool) {
+	list := []Expression{}
 
-	// Check if the array is empty
-	if p.peekToken.Type != lexer.RSBREC {
+	// Check if the list is empty
+	if p.peekToken.Type == end {
 		p.nextToken()
-		values = append(values, p.parseExpression())
+		return list, true
+	}
 
-		// Parse remaining elements
-		for p.peekToken.Type == lexer.COMMA {
-			p.nextToken() // consume comma
-			p.nextToken() // move to next expression
-			values = append(values, p.parseExpression())
+	p.nextToken()
+	expr := p.parseExpression()
+	if expr == nil {
+		return nil, false
+	}
+	list = append(list, expr)
+
+	// Parse remaining elements
+	for p.peekToken.Type == lexer.COMMA {
+		p.nextToken() // consume comma
+		p.nextToken() // move to next expression
+		expr := p.parseExpression()
+		if expr == nil {
+			return nil, false
 		}
+		list = append(list, expr)
 	}
 
-	// Expect closing bracket
-	if !p.expectPeek(lexer.RSBREC) {
-		return nil
+	if !p.expectPeek(end) {
+		return nil, false
 	}
 
-	stmt.Values = values
-	return stmt
+	return list, true
 }
 
 func (p *Parser) parseIndexExpression(left Expression) Expression {
@@ -121,17 +266,22 @@ func (p *Parser) parseIndexExpression(left Expression) Expression {
 
 	// Parse the index expression
 	expr.Index = p.parseExpression()
+	if expr.Index == nil {
+		return nil
+	}
 
 	// Expect closing bracket
 	if !p.expectPeek(lexer.RSBREC) {
 		return nil
 	}
 
+	expr.Span = p.span(left.Pos())
 	return expr
 }
 
-func (p *Parser) parseVarStatement() *VarStatement {
+func (p *Parser) parseVarStatement() Statement {
 	stmt := &VarStatement{}
+	start := p.currentToken.Pos
 
 	if !p.expectPeek(lexer.IDENT) {
 		return nil
@@ -139,18 +289,65 @@ func (p *Parser) parseVarStatement() *VarStatement {
 
 	stmt.Name = p.currentToken.Literal
 
+	// An optional type annotation sits between the name and `=`. Tokens
+	// that do not form a type followed by `=` are reported as a missing
+	// `=`, which is the likelier mistake.
+	if p.peekToken.Type == lexer.IDENT || p.peekToken.Type == lexer.LSBREC {
+		name, next := p.currentToken, p.peekToken
+		errors := len(p.errors)
+		p.nextToken()
+		stmt.Type = p.parseType()
+		if stmt.Type == nil || p.peekToken.Type != lexer.ASSIGN {
+			p.errors = p.errors[:errors]
+			p.errorAt(next, "expected %s after %s, got %s", lexer.ASSIGN, name.Describe(), next.Describe())
+			return nil
+		}
+	}
+
 	if !p.expectPeek(lexer.ASSIGN) {
 		return nil
 	}
 
 	p.nextToken()
 	stmt.Value = p.parseExpression()
+	if stmt.Value == nil {
+		return nil
+	}
 
+	stmt.Span = p.span(start)
 	return stmt
 }
 
-func (p *Parser) parseForStatement() *ForStatement {
+// parseConstStatement parses `const name [type] = value`, which has the same
+// shape as a `lazy` declaration.
+func (p *Parser) parseConstStatement() Statement {
+	stmt := p.parseVarStatement()
+	if stmt == nil {
+		return nil
+	}
+
+	v := stmt.(*VarStatement)
+	return &ConstStatement{Span: v.Span, Name: v.Name, Type: v.Type, Value: v.Value}
+}
+
+// parseLazyStatement parses `lazyOnce name [type] = value`.
+func (p *Parser) parseLazyStatement() Statement {
+	stmt := p.parseVarStatement()
+	if stmt == nil {
+		return nil
+	}
+
+	v := stmt.(*VarStatement)
+	return &LazyStatement{Span: v.Span, Name: v.Name, Type: v.Type, Value: v.Value}
+}
+
+func (p *Parser) parseForStatement() Statement {
+	if p.peekToken.Type == lexer.IDENT {
+		return p.parseForInStatement()
+	}
+
 	stmt := &ForStatement{}
+	start := p.currentToken.Pos
 
 	if !p.expectPeek(lexer.LPAREN) {
 		return nil
@@ -160,6 +357,7 @@ func (p *Parser) parseForStatement() *ForStatement {
 	if p.currentToken.Type != lexer.SEMICOLON {
 		if p.currentToken.Type == lexer.IDENT {
 			name := p.currentToken.Literal
+			clauseStart := p.currentToken.Pos
 
 			if !p.expectPeek(lexer.ASSIGN) {
 				return nil
@@ -167,24 +365,31 @@ func (p *Parser) parseForStatement() *ForStatement {
 
 			p.nextToken()
 			value := p.parseExpression()
+			if value == nil {
+				return nil
+			}
 
 			stmt.Init = &VarStatement{
+				Span:  p.span(clauseStart),
 				Name:  name,
 				Value: value,
 			}
 		} else {
+			p.errorAt(p.currentToken, "expected loop variable or `;` in for-loop init, got %s", p.currentToken.Describe())
 			return nil
 		}
 
 		if !p.expectPeek(lexer.SEMICOLON) {
 			return nil
 		}
-	} else {
-		p.nextToken()
 	}
+	p.nextToken() // Move past the first `;`
 
 	if p.currentToken.Type != lexer.SEMICOLON {
 		stmt.Condition = p.parseExpression()
+		if stmt.Condition == nil {
+			return nil
+		}
 	}
 
 	if !p.expectPeek(lexer.SEMICOLON) {
@@ -195,20 +400,12 @@ func (p *Parser) parseForStatement() *ForStatement {
 		p.nextToken() // Move to the first token of the post statement
 
 		if p.currentToken.Type == lexer.IDENT {
-			name := p.currentToken.Literal
-
-			if !p.expectPeek(lexer.ASSIGN) {
+			stmt.Post = p.parseSimpleStatement()
+			if stmt.Post == nil {
 				return nil
 			}
-
-			p.nextToken()
-			value := p.parseExpression()
-
-			stmt.Post = &VarStatement{
-				Name:  name,
-				Value: value,
-			}
 		} else {
+			p.errorAt(p.currentToken, "expected assignment or `)` in for-loop post statement, got %s", p.currentToken.Describe())
 			return nil
 		}
 	}
@@ -224,14 +421,137 @@ func (p *Parser) parseForStatement() *ForStatement {
 	p.nextToken() // Move to the first token in the body
 	stmt.Body = p.parseBlockStatement()
 
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// parseForInStatement parses `for x in iterable { ... }` and
+// `for i, x in iterable { ... }`, where iterable is an array or a range.
+func (p *Parser) parseForInStatement() Statement {
+	stmt := &ForInStatement{}
+	start := p.currentToken.Pos
+
+	p.nextToken()
+	stmt.Value = &Identifier{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Literal}
+
+	if p.peekToken.Type == lexer.COMMA {
+		p.nextToken()
+		if !p.expectPeek(lexer.IDENT) {
+			return nil
+		}
+		stmt.Index = stmt.Value
+		stmt.Value = &Identifier{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Literal}
+	}
+
+	if !p.expectPeek(lexer.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression()
+	if stmt.Iterable == nil {
+		return nil
+	}
+
+	if p.peekToken.Type == lexer.DOTDOT || p.peekToken.Type == lexer.DOTDOT_EQ {
+		p.nextToken()
+		rng := &RangeExpression{From: stmt.Iterable, Inclusive: p.currentToken.Type == lexer.DOTDOT_EQ}
+
+		p.nextToken()
+		rng.To = p.parseExpression()
+		if rng.To == nil {
+			return nil
+		}
+
+		rng.Span = p.span(rng.From.Pos())
+		stmt.Iterable = rng
+	}
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	p.nextToken() // Move to the first token in the body
+	stmt.Body = p.parseBlockStatement()
+
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// parseLabeledLoop parses a loop with a label, which break and continue in
+// nested loops can refer to: `outer: for x in xs { ... }`.
+func (p *Parser) parseLabeledLoop() Statement {
+	label := &Identifier{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Literal}
+	p.nextToken() // consume the label
+	p.nextToken() // consume the ':'
+
+	var stmt Statement
+	switch p.currentToken.Type {
+	case lexer.FOR:
+		stmt = p.parseForStatement()
+	case lexer.WHILE:
+		stmt = p.parseWhileStatement()
+	default:
+		p.errorAt(p.currentToken, "expected loop after label %s, got %s", label.Value, p.currentToken.Describe())
+		return nil
+	}
+
+	switch s := stmt.(type) {
+	case *ForStatement:
+		s.Label, s.StartPos = label, label.Pos()
+	case *ForInStatement:
+		s.Label, s.StartPos = label, label.Pos()
+	case *WhileStatement:
+		s.Label, s.StartPos = label, label.Pos()
+	}
+	return stmt
+}
+
+// parseBranchStatement parses `break` or `continue`, optionally followed by
+// the label of the loop on the same line.
+func (p *Parser) parseBranchStatement() Statement {
+	stmt := &BranchStatement{Keyword: p.currentToken.Literal}
+	start := p.currentToken.Pos
+
+	if p.peekToken.Type == lexer.IDENT && p.peekToken.Pos.Line == p.currentToken.End.Line {
+		p.nextToken()
+		stmt.Label = &Identifier{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Literal}
+	}
+
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() Statement {
+	stmt := &WhileStatement{}
+	start := p.currentToken.Pos
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression()
+	if stmt.Condition == nil {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	p.nextToken() // Move to the first token in the body
+	stmt.Body = p.parseBlockStatement()
+
+	stmt.Span = p.span(start)
 	return stmt
 }
 
-func (p *Parser) parseIfStatement() *IfStatement {
+func (p *Parser) parseIfStatement() Statement {
 	stmt := &IfStatement{}
+	start := p.currentToken.Pos
 
 	p.nextToken()
 	stmt.Condition = p.parseExpression()
+	if stmt.Condition == nil {
+		return nil
+	}
 
 	if !p.expectPeek(lexer.LBRACE) {
 		return nil
@@ -243,6 +563,18 @@ func (p *Parser) parseIfStatement() *IfStatement {
 	if p.peekToken.Type == lexer.ELSE {
 		p.nextToken()
 
+		// `el if` continues the chain: the alternative is the next if alone
+		if p.peekToken.Type == lexer.IF {
+			p.nextToken()
+			alternative := p.parseIfStatement()
+			if alternative == nil {
+				return nil
+			}
+			stmt.Alternative = []Statement{alternative}
+			stmt.Span = p.span(start)
+			return stmt
+		}
+
 		if !p.expectPeek(lexer.LBRACE) {
 			return nil
 		}
@@ -251,58 +583,417 @@ func (p *Parser) parseIfStatement() *IfStatement {
 		stmt.Alternative = p.parseBlockStatement()
 	}
 
+	stmt.Span = p.span(start)
 	return stmt
 }
 
+func (p *Parser) parseMatchStatement() Statement {
+	stmt := &MatchStatement{}
+	start := p.currentToken.Pos
+
+	p.nextToken()
+	stmt.Value = p.parseExpression()
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	p.nextToken() // Move to the first pattern
+	for p.currentToken.Type != lexer.RBRACE {
+		if p.currentToken.Type == lexer.EOF {
+			p.errorAt(p.currentToken, "expected `}` to close match, got end of file")
+			return nil
+		}
+
+		armStart := p.currentToken.Pos
+		arm := p.parseMatchArm()
+		if arm == nil {
+			// Skip the broken arm and carry on with the next one
+			p.synchronizeArm(armStart)
+			continue
+		}
+		stmt.Arms = append(stmt.Arms, arm)
+		p.nextToken()
+	}
+
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// synchronizeArm recovers from a syntax error in the match arm that began at
+// start. Like synchronize it skips blocks as a whole, and it stops at the
+// first token of the next line, after a `,` between arms, or at the `}` that
+// closes the match.
+func (p *Parser) synchronizeArm(start lexer.Position) {
+	depth := 0
+	line := start.Line
+	for p.currentToken.Type != lexer.EOF {
+		tok := p.currentToken
+		if depth == 0 && tok.Pos != start {
+			if tok.Type == lexer.RBRACE || tok.Pos.Line > line {
+				return
+			}
+			if tok.Type == lexer.COMMA {
+				p.nextToken()
+				return
+			}
+		}
+
+		switch tok.Type {
+		case lexer.LBRACE:
+			depth++
+		case lexer.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+		line = tok.End.Line
+		p.nextToken()
+	}
+}
+
+// parseMatchArm parses `patterns => statement` or `patterns => { ... }`,
+// where patterns is `_` or a comma separated list of expressions. On success
+// the current token is the last one of the arm.
+func (p *Parser) parseMatchArm() *MatchArm {
+	arm := &MatchArm{}
+	start := p.currentToken.Pos
+
+	if p.currentToken.Type == lexer.IDENT && p.currentToken.Literal == "_" && p.peekToken.Type == lexer.ARROW {
+		p.nextToken()
+	} else {
+		for {
+			pattern := p.parseExpression()
+			if pattern == nil {
+				return nil
+			}
+			arm.Patterns = append(arm.Patterns, pattern)
+
+			if p.peekToken.Type != lexer.COMMA {
+				break
+			}
+			p.nextToken() // consume comma
+			p.nextToken() // move to next pattern
+		}
+
+		if !p.expectPeek(lexer.ARROW) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	if p.currentToken.Type == lexer.LBRACE {
+		p.nextToken() // Move to the first token in the body
+		arm.Body = p.parseBlockStatement()
+	} else {
+		body := p.parseStatement()
+		if body == nil {
+			return nil
+		}
+		arm.Body = []Statement{body}
+	}
+
+	arm.Span = p.span(start)
+
+	// Arms may be separated by commas as well as by new lines
+	if p.peekToken.Type == lexer.COMMA {
+		p.nextToken()
+	}
+	return arm
+}
+
 func (p *Parser) parseBlockStatement() []Statement {
 	statements := []Statement{}
 
 	for p.currentToken.Type != lexer.RBRACE && p.currentToken.Type != lexer.EOF {
+		start := p.currentToken.Pos
 		stmt := p.parseStatement()
-		if stmt != nil {
-			statements = append(statements, stmt)
+		if stmt == nil {
+			p.synchronize(start)
+			continue
 		}
+		statements = append(statements, stmt)
 		p.nextToken()
 	}
 
+	if p.currentToken.Type == lexer.EOF {
+		p.errorAt(p.currentToken, "expected `}` to close block, got end of file")
+	}
+
 	return statements
 }
 
-func (p *Parser) parsePrintStatement() *PrintStatement {
-	stmt := &PrintStatement{}
+func (p *Parser) parseFunctionStatement() Statement {
+	stmt := &FunctionStatement{}
+	start := p.currentToken.Pos
+
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
+	}
+	stmt.Name = p.currentToken.Literal
 
 	if !p.expectPeek(lexer.LPAREN) {
 		return nil
 	}
 
-	p.nextToken()
-	stmt.Value = p.parseExpression()
+	params, ok := p.parseParameters()
+	if !ok {
+		return nil
+	}
+	stmt.Parameters = params
+
+	// An optional return type sits between `)` and `{`
+	if p.peekToken.Type != lexer.LBRACE {
+		p.nextToken()
+		stmt.ReturnType = p.parseType()
+		if stmt.ReturnType == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	p.nextToken() // Move to the first token in the body
+	stmt.Body = p.parseBlockStatement()
+
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// parseRecordStatement parses `record Name { field [type], ... }`. Fields are
+// separated by commas, and may be spread over several lines.
+func (p *Parser) parseRecordStatement() Statement {
+	stmt := &RecordStatement{}
+	start := p.currentToken.Pos
+
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
+	}
+	stmt.Name = p.currentToken.Literal
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	for p.peekToken.Type != lexer.RBRACE {
+		if !p.expectPeek(lexer.IDENT) {
+			return nil
+		}
+		field := &RecordField{Name: p.currentToken.Literal}
+		fieldStart := p.currentToken.Pos
+
+		// An optional type follows the name
+		if p.peekToken.Type != lexer.COMMA && p.peekToken.Type != lexer.RBRACE {
+			p.nextToken()
+			field.Type = p.parseType()
+			if field.Type == nil {
+				return nil
+			}
+		}
+		field.Span = p.span(fieldStart)
+		stmt.Fields = append(stmt.Fields, field)
+
+		if p.peekToken.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken() // consume comma, a trailing one is allowed
+	}
+
+	if !p.expectPeek(lexer.RBRACE) {
+		return nil
+	}
+
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// parseParameters parses `name type, ...` up to the closing parenthesis. The
+// current token is the opening parenthesis.
+func (p *Parser) parseParameters() ([]*Parameter, bool) {
+	params := []*Parameter{}
+
+	if p.peekToken.Type == lexer.RPAREN {
+		p.nextToken()
+		return params, true
+	}
+
+	for {
+		if !p.expectPeek(lexer.IDENT) {
+			return nil, false
+		}
+		param := &Parameter{Name: p.currentToken.Literal}
+		start := p.currentToken.Pos
+
+		p.nextToken()
+		param.Type = p.parseType()
+		if param.Type == nil {
+			return nil, false
+		}
+		param.Span = p.span(start)
+		params = append(params, param)
+
+		if p.peekToken.Type != lexer.COMMA {
+			break
+		}
+		p.nextToken() // consume comma
+	}
 
 	if !p.expectPeek(lexer.RPAREN) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// parseType parses a type annotation starting at the current token.
+func (p *Parser) parseType() *TypeExpr {
+	start := p.currentToken.Pos
+
+	switch p.currentToken.Type {
+	case lexer.IDENT:
+		return &TypeExpr{Span: p.span(start), Name: p.currentToken.Literal}
+	case lexer.LSBREC:
+		if !p.expectPeek(lexer.RSBREC) {
+			return nil
+		}
+		p.nextToken()
+		elem := p.parseType()
+		if elem == nil {
+			return nil
+		}
+		return &TypeExpr{Span: p.span(start), Elem: elem}
+	default:
+		p.errorAt(p.currentToken, "expected type, got %s", p.currentToken.Describe())
 		return nil
 	}
+}
+
+func (p *Parser) parseReturnStatement() Statement {
+	stmt := &ReturnStatement{}
+	start := p.currentToken.Pos
 
+	// A value must start on the same line as `return`
+	if p.peekToken.Type != lexer.RBRACE && p.peekToken.Type != lexer.EOF &&
+		p.peekToken.Pos.Line == p.currentToken.Pos.Line {
+		p.nextToken()
+		stmt.Value = p.parseExpression()
+		if stmt.Value == nil {
+			return nil
+		}
+	}
+
+	stmt.Span = p.span(start)
 	return stmt
 }
 
+// parseSimpleStatement parses a statement that begins with an expression: a
+// call, an assignment such as `x = 1` or `x += 1`, or `x++` and `x--`.
+func (p *Parser) parseSimpleStatement() Statement {
+	start := p.currentToken.Pos
+
+	expr := p.parseExpression()
+	if expr == nil {
+		return nil
+	}
+
+	switch p.peekToken.Type {
+	case lexer.ASSIGN:
+		p.nextToken()
+		stmt := &AssignStatement{Target: expr}
+
+		p.nextToken()
+		stmt.Value = p.parseExpression()
+		if stmt.Value == nil {
+			return nil
+		}
+
+		stmt.Span = p.span(start)
+		return stmt
+	case lexer.PLUS_ASSIGN, lexer.MINUS_ASSIGN, lexer.MULTIPLY_ASSIGN, lexer.DIVIDE_ASSIGN, lexer.MODULO_ASSIGN:
+		p.nextToken()
+		stmt := &CompoundAssignStatement{Target: expr, Operator: p.currentToken.Literal}
+
+		p.nextToken()
+		stmt.Value = p.parseExpression()
+		if stmt.Value == nil {
+			return nil
+		}
+
+		stmt.Span = p.span(start)
+		return stmt
+	case lexer.INCREMENT, lexer.DECREMENT:
+		p.nextToken()
+		return &IncDecStatement{Span: p.span(start), Target: expr, Operator: p.currentToken.Literal}
+	}
+
+	if _, ok := expr.(*CallExpression); !ok {
+		p.errorRange(expr.Pos(), expr.End(), "%s is not a statement", expr.String())
+		return nil
+	}
+
+	return &ExpressionStatement{Span: p.span(start), Expression: expr}
+}
+
+func (p *Parser) parsePrintStatement() Statement {
+	stmt := &PrintStatement{}
+	start := p.currentToken.Pos
+
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+
+	values, ok := p.parseExpressionList(lexer.RPAREN)
+	if !ok {
+		return nil
+	}
+	stmt.Values = values
+
+	stmt.Span = p.span(start)
+	return stmt
+}
+
+// Binding powers of operators, from loosest to tightest.
+const (
+	lowest int = iota
+	logicalOr
+	logicalAnd
+	comparison
+	sum     // + - | ^
+	product // * / % & << >>
+	prefix  // !x, -x
+	power   // a ** b, binds tighter than a unary operator on its left
+	postfix // a[i], f(x), p.x
+)
+
 func (p *Parser) precedence(tokenType lexer.TokenType) int {
 
 	switch tokenType {
-	case lexer.LSBREC:
-		return 4
-	case lexer.MULTIPLY, lexer.DIVIDE:
-		return 3
-	case lexer.PLUS, lexer.MINUS:
-		return 2
+	case lexer.LSBREC, lexer.LPAREN, lexer.DOT:
+		return postfix
+	case lexer.POWER:
+		return power
+	case lexer.MULTIPLY, lexer.DIVIDE, lexer.MODULO, lexer.BIT_AND, lexer.SHL, lexer.SHR:
+		return product
+	case lexer.PLUS, lexer.MINUS, lexer.BIT_OR, lexer.BIT_XOR:
+		return sum
 	case lexer.GT, lexer.LT, lexer.EQ, lexer.NOT_EQ, lexer.GT_EQ, lexer.LT_EQ:
-		return 1
+		return comparison
+	case lexer.AND:
+		return logicalAnd
+	case lexer.OR:
+		return logicalOr
 	default:
-		return 0
+		return lowest
 	}
 }
 
 func (p *Parser) parseExpression() Expression {
-	return p.parseExpressionWithPrecedence(0)
+	return p.parseExpressionWithPrecedence(lowest)
 }
 
 func (p *Parser) parseExpressionWithPrecedence(precedence int) Expression {
@@ -311,29 +1002,111 @@ func (p *Parser) parseExpressionWithPrecedence(precedence int) Expression {
 	if left == nil {
 		return nil
 	}
+	if p.peekToken.Type == lexer.ILLEGAL && p.peekToken.Pos.Line == p.currentToken.End.Line {
+		p.errorAt(p.peekToken, "unexpected %s in expression", p.peekToken.Describe())
+		return nil
+	}
 
+	// An operator must be on the same line as its left operand, like in Go.
+	// Otherwise `-1` or `(x)` at the start of a line would continue the
+	// expression on the line before.
 	for p.peekToken.Type != lexer.EOF &&
 		p.peekToken.Type != lexer.SEMICOLON &&
 		p.peekToken.Type != lexer.RPAREN &&
 		p.peekToken.Type != lexer.RBRACE &&
+		p.peekToken.Pos.Line == p.currentToken.End.Line &&
 		precedence < p.precedence(p.peekToken.Type) {
 
 		switch p.peekToken.Type {
-		case lexer.PLUS, lexer.MINUS, lexer.MULTIPLY, lexer.DIVIDE, lexer.GT, lexer.LT, lexer.EQ, lexer.GT_EQ, lexer.LT_EQ, lexer.NOT_EQ:
+		case lexer.PLUS, lexer.MINUS, lexer.MULTIPLY, lexer.DIVIDE, lexer.GT, lexer.LT, lexer.EQ, lexer.GT_EQ, lexer.LT_EQ, lexer.NOT_EQ,
+			lexer.AND, lexer.OR, lexer.MODULO, lexer.POWER, lexer.BIT_AND, lexer.BIT_OR, lexer.BIT_XOR, lexer.SHL, lexer.SHR:
 			p.nextToken()
 			left = p.parseInfixExpression(left)
 		case lexer.LSBREC:
 			p.nextToken()
 			left = p.parseIndexExpression(left)
+		case lexer.LPAREN:
+			p.nextToken()
+			left = p.parseCallExpression(left)
+		case lexer.DOT:
+			p.nextToken()
+			left = p.parseFieldExpression(left)
 
 		default:
 			return left
 		}
+
+		if left == nil {
+			return nil
+		}
 	}
 
 	return left
 }
 
+func (p *Parser) parseCallExpression(function Expression) Expression {
+	expr := &CallExpression{Function: function}
+
+	args, ok := p.parseExpressionList(lexer.RPAREN)
+	if !ok {
+		return nil
+	}
+	expr.Arguments = args
+
+	expr.Span = p.span(function.Pos())
+	return expr
+}
+
+func (p *Parser) parseFieldExpression(record Expression) Expression {
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
+	}
+	field := &Identifier{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Literal}
+	return &FieldExpression{Span: p.span(record.Pos()), Record: record, Field: field}
+}
+
+func (p *Parser) parsePrefixExpression() Expression {
+	expression := &PrefixExpression{Operator: p.currentToken.Literal}
+	start := p.currentToken.Pos
+
+	p.nextToken()
+	expression.Right = p.parseExpressionWithPrecedence(prefix)
+	if expression.Right == nil {
+		return nil
+	}
+
+	expression.Span = p.span(start)
+	return expression
+}
+
+// parseGroupedExpression parses a parenthesized expression. The parentheses
+// only steer parsing, the tree shape already records the grouping.
+func (p *Parser) parseGroupedExpression() Expression {
+	p.nextToken()
+
+	expression := p.parseExpression()
+	if expression == nil {
+		return nil
+	}
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseArrayLiteral() Expression {
+	start := p.currentToken.Pos
+
+	elements, ok := p.parseExpressionList(lexer.RSBREC)
+	if !ok {
+		return nil
+	}
+
+	return &ArrayLiteral{Span: p.span(start), Elements: elements}
+}
+
 func (p *Parser) parseInfixExpression(left Expression) Expression {
 	expression := &InfixExpression{
 		Left:     left,
@@ -341,20 +1114,62 @@ func (p *Parser) parseInfixExpression(left Expression) Expression {
 	}
 
 	precedence := p.precedence(p.currentToken.Type)
+	if p.currentToken.Type == lexer.POWER {
+		// Right associative: 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpressionWithPrecedence(precedence)
+	if expression.Right == nil {
+		return nil
+	}
 
+	expression.Span = p.span(left.Pos())
 	return expression
 }
 
 func (p *Parser) parsePrimary() Expression {
 	switch p.currentToken.Type {
 	case lexer.IDENT:
-		return &Identifier{Value: p.currentToken.Literal}
-	case lexer.NUMBER:
-		value, _ := strconv.ParseFloat(p.currentToken.Literal, 64)
-		return &NumberLiteral{Value: value}
+		return &Identifier{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Literal}
+	case lexer.INT:
+		value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
+		if err != nil {
+			p.errorAt(p.currentToken, "invalid integer %s", p.currentToken.Literal)
+			return nil
+		}
+		return &IntegerLiteral{Span: p.span(p.currentToken.Pos), Value: value}
+	case lexer.FLOAT:
+		// The lexer checked that underscores only separate digits
+		value, err := strconv.ParseFloat(strings.ReplaceAll(p.currentToken.Literal, "_", ""), 64)
+		if err != nil {
+			p.errorAt(p.currentToken, "invalid float %s", p.currentToken.Literal)
+			return nil
+		}
+		return &FloatLiteral{Span: p.span(p.currentToken.Pos), Value: value}
+	case lexer.TRUE, lexer.FALSE:
+		return &BooleanLiteral{Span: p.span(p.currentToken.Pos), Value: p.currentToken.Type == lexer.TRUE}
+	case lexer.BANG, lexer.MINUS:
+		return p.parsePrefixExpression()
+	case lexer.LPAREN:
+		return p.parseGroupedExpression()
+	case lexer.LSBREC:
+		return p.parseArrayLiteral()
+	case lexer.STRING:
+		value, err := strconv.Unquote(p.currentToken.Literal)
+		if err != nil {
+			p.errorAt(p.currentToken, "invalid string literal %s", p.currentToken.Literal)
+			return nil
+		}
+		return &StringLiteral{Span: p.span(p.currentToken.Pos), Value: value}
 	default:
+		// An expression cut off at the end of a line is reported there,
+		// not at the start of the next line
+		if prev := p.previousToken; prev.End.IsValid() && p.currentToken.Pos.Line > prev.End.Line {
+			p.errorAt(prev, "expected expression after %s at end of line", prev.Describe())
+			return nil
+		}
+		p.errorAt(p.currentToken, "expected expression, got %s", p.currentToken.Describe())
 		return nil
 	}
 }
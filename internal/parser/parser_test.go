@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lazydiv/lazyLang-compiler/internal/lexer"
+)
+
+func parse(t *testing.T, input string) *Program {
+	t.Helper()
+	l := lexer.NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	for _, err := range l.Errors() {
+		t.Errorf("lexer error: %s", err.Msg)
+	}
+	for _, err := range p.Errors() {
+		t.Errorf("parser error: %s", err.Msg)
+	}
+	return program
+}
+
+// checkWhile checks that stmt is a while loop with the given condition and
+// number of statements in its body.
+func checkWhile(t *testing.T, stmt Statement, condition string, body int) {
+	t.Helper()
+	ws, ok := stmt.(*WhileStatement)
+	if !ok {
+		t.Fatalf("statement is %T, want *WhileStatement", stmt)
+	}
+	if got := ws.Condition.String(); got != condition {
+		t.Errorf("while condition is %s, want %s", got, condition)
+	}
+	if len(ws.Body) != body {
+		t.Errorf("while body has %d statements, want %d", len(ws.Body), body)
+	}
+}
+
+func TestWhileInsideIf(t *testing.T) {
+	program := parse(t, `
+lazy n = 3
+if n > 0 {
+  lazy m = 0
+  while m < n {
+    lazyPrint(m)
+    m++
+  }
+}
+`)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program has %d statements, want 2", len(program.Statements))
+	}
+	is, ok := program.Statements[1].(*IfStatement)
+	if !ok {
+		t.Fatalf("statement is %T, want *IfStatement", program.Statements[1])
+	}
+	if len(is.Consequence) != 2 {
+		t.Fatalf("if body has %d statements, want 2", len(is.Consequence))
+	}
+	checkWhile(t, is.Consequence[1], "(m < n)", 2)
+}
+
+func TestWhileInsideFor(t *testing.T) {
+	program := parse(t, `
+for (i = 0; i < 2; i++) {
+  lazy j = 0
+  while j < 2 {
+    j++
+  }
+}
+`)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has %d statements, want 1", len(program.Statements))
+	}
+	fs, ok := program.Statements[0].(*ForStatement)
+	if !ok {
+		t.Fatalf("statement is %T, want *ForStatement", program.Statements[0])
+	}
+	if len(fs.Body) != 2 {
+		t.Fatalf("for body has %d statements, want 2", len(fs.Body))
+	}
+	checkWhile(t, fs.Body[1], "(j < 2)", 1)
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		pos   string // line:column of the first error
+		msg   string
+	}{
+		{"missing assign", "lazy x [ 3", "1:8", "expected `=` after identifier `x`, got `[`"},
+		{"trailing operator", "lazy x = 1 +\nlazyPrint(x)", "1:12", "expected expression after `+` at end of line"},
+		{"trailing assign", "lazy x =\nlazyPrint(x)", "1:8", "expected expression after `=` at end of line"},
+		{"missing operand", "lazy x = 1 + )", "1:14", "expected expression, got `)`"},
+		{"illegal character", "lazy z = 4 $ 5", "1:12", "unexpected illegal character `$` in expression"},
+		{"illegal statement", "$", "1:1", "unexpected illegal character `$` at start of statement"},
+		{"unclosed call", "lazyPrint(1", "1:12", "expected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(lexer.NewLexer(tt.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatalf("no errors, want %q", tt.msg)
+			}
+			err := p.Errors()[0]
+			if pos := fmt.Sprintf("%d:%d", err.Pos.Line, err.Pos.Column); pos != tt.pos {
+				t.Errorf("error at %s, want %s", pos, tt.pos)
+			}
+			if !strings.Contains(err.Msg, tt.msg) {
+				t.Errorf("error is %q, want %q", err.Msg, tt.msg)
+			}
+		})
+	}
+}
+
+func TestSpans(t *testing.T) {
+	program := parse(t, "lazy x = 1 + 23\nif x > 2 {\n  lazyPrint(\"big\")\n}")
+	is := program.Statements[1].(*IfStatement)
+	tests := []struct {
+		name       string
+		node       Node
+		start, end string // line:column
+	}{
+		{"statement", program.Statements[0], "1:1", "1:16"},
+		{"infix", program.Statements[0].(*VarStatement).Value, "1:10", "1:16"},
+		{"operand", program.Statements[0].(*VarStatement).Value.(*InfixExpression).Right, "1:14", "1:16"},
+		{"if", is, "2:1", "4:2"},
+		{"condition", is.Condition, "2:4", "2:9"},
+		{"print", is.Consequence[0], "3:3", "3:19"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := fmt.Sprintf("%d:%d", tt.node.Pos().Line, tt.node.Pos().Column)
+			end := fmt.Sprintf("%d:%d", tt.node.End().Line, tt.node.End().Column)
+			if start != tt.start || end != tt.end {
+				t.Errorf("%s spans %s-%s, want %s-%s", tt.node.String(), start, end, tt.start, tt.end)
+			}
+		})
+	}
+
+	// Offsets count bytes from the start of the input
+	if off := is.Condition.Pos().Offset; off != 19 {
+		t.Errorf("condition starts at offset %d, want 19", off)
+	}
+}
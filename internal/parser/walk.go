@@ -0,0 +1,100 @@
+package parser
+
+// Inspect traverses the tree rooted at node in depth-first order, like
+// go/ast.Inspect: it calls f(node), and if f returns true it inspects the
+// children of node in source order.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *VarStatement:
+		Inspect(n.Value, f)
+	case *ConstStatement:
+		Inspect(n.Value, f)
+	case *LazyStatement:
+		Inspect(n.Value, f)
+	case *ArrayStatement:
+		inspectExpressions(n.Values, f)
+	case *AssignStatement:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *CompoundAssignStatement:
+		Inspect(n.Target, f)
+		Inspect(n.Value, f)
+	case *IncDecStatement:
+		Inspect(n.Target, f)
+	case *IfStatement:
+		Inspect(n.Condition, f)
+		inspectStatements(n.Consequence, f)
+		inspectStatements(n.Alternative, f)
+	case *ForStatement:
+		inspectOptional(n.Init, f)
+		inspectOptional(n.Condition, f)
+		inspectOptional(n.Post, f)
+		inspectStatements(n.Body, f)
+	case *ForInStatement:
+		Inspect(n.Iterable, f)
+		inspectStatements(n.Body, f)
+	case *WhileStatement:
+		Inspect(n.Condition, f)
+		inspectStatements(n.Body, f)
+	case *MatchStatement:
+		Inspect(n.Value, f)
+		for _, arm := range n.Arms {
+			Inspect(arm, f)
+		}
+	case *MatchArm:
+		inspectExpressions(n.Patterns, f)
+		inspectStatements(n.Body, f)
+	case *FunctionStatement:
+		inspectStatements(n.Body, f)
+	case *ReturnStatement:
+		inspectOptional(n.Value, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *PrintStatement:
+		inspectExpressions(n.Values, f)
+	case *ArrayLiteral:
+		inspectExpressions(n.Elements, f)
+	case *IndexExpression:
+		Inspect(n.Array, f)
+		Inspect(n.Index, f)
+	case *FieldExpression:
+		Inspect(n.Record, f)
+		Inspect(n.Field, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		inspectExpressions(n.Arguments, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *RangeExpression:
+		Inspect(n.From, f)
+		Inspect(n.To, f)
+	}
+}
+
+// inspectOptional inspects an optional child, which may be a nil pointer
+// stored in an interface.
+func inspectOptional[T Node](node T, f func(Node) bool) {
+	var zero T
+	if Node(node) != Node(zero) {
+		Inspect(node, f)
+	}
+}
+
+func inspectStatements(stmts []Statement, f func(Node) bool) {
+	for _, stmt := range stmts {
+		Inspect(stmt, f)
+	}
+}
+
+func inspectExpressions(exprs []Expression, f func(Node) bool) {
+	for _, expr := range exprs {
+		Inspect(expr, f)
+	}
+}